@@ -0,0 +1,119 @@
+package crud
+
+import (
+	"database/sql"
+	"strings"
+)
+
+//aggregateQuery 聚合查询的语句，忽略ORDER BY、LIMIT、OFFSET、游标和锁。
+//有GROUP BY、UNION或者DISTINCT的时候结果的行数和直接聚合不一样，所以用子查询，子查询外面使用outerExpr。
+//field是expr中使用的字段，是其他表的字段时自动join这张表，没有的时候为""。
+func (s *Search) aggregateQuery(expr, outerExpr, field string) (string, []interface{}) {
+	agg := s.Clone()
+	agg.cursor = nil
+	agg.lock = ""
+	agg.lockOption = ""
+	agg.orders = nil
+	agg.limit = nil
+	agg.offset = nil
+	if agg.group != "" || len(agg.unions) > 0 || agg.isDistinct() {
+		if agg.group != "" && len(agg.fields) == 0 && len(agg.unions) == 0 {
+			//ONLY_FULL_GROUP_BY不允许 SELECT * ... GROUP BY，只查询分组的字段
+			agg.selects = agg.group
+		}
+		query, args := agg.Parse()
+		return "SELECT " + outerExpr + " FROM (" + query + ") AS `crud_aggregate`", args
+	}
+	if field != "" {
+		agg = agg.joinField(field)
+	}
+	agg.selects = expr
+	return agg.Parse()
+}
+
+//aggregateFieldQuery fn(field)的语句，distinct为true时是fn(DISTINCT field)，field会检查是否在表中。
+//有GROUP BY的时候聚合的是子查询的结果，所以field需要在Fields中。
+func (s *Search) aggregateFieldQuery(fn string, distinct bool, field string) (string, []interface{}, error) {
+	column, err := s.column(field)
+	if err != nil {
+		return "", nil, err
+	}
+	outer := "`" + field[strings.LastIndex(field, ".")+1:] + "`"
+	if distinct {
+		column, outer = "DISTINCT "+column, "DISTINCT "+outer
+	}
+	query, args := s.aggregateQuery(fn+"("+column+")", fn+"("+outer+")", field)
+	return query, args, nil
+}
+
+//aggregate 执行聚合函数，见aggregateFieldQuery。
+func (s *Search) aggregate(fn string, distinct bool, field string, dest interface{}) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	query, args, err := s.aggregateFieldQuery(fn, distinct, field)
+	if err != nil {
+		return err
+	}
+	return s.table.Query(query, args...).Scan(dest)
+}
+
+//Sum SUM(field)，没有数据的时候是0。
+func (s *Search) Sum(field string) (float64, error) {
+	var sum sql.NullFloat64
+	err := s.aggregate("SUM", false, field, &sum)
+	return sum.Float64, err
+}
+
+//Avg AVG(field)，没有数据的时候是0。
+func (s *Search) Avg(field string) (float64, error) {
+	var avg sql.NullFloat64
+	err := s.aggregate("AVG", false, field, &avg)
+	return avg.Float64, err
+}
+
+//Max MAX(field)，没有数据的时候Valid为false，可以用于数字和时间。
+func (s *Search) Max(field string) (sql.NullString, error) {
+	var max sql.NullString
+	err := s.aggregate("MAX", false, field, &max)
+	return max, err
+}
+
+//Min MIN(field)，没有数据的时候Valid为false，可以用于数字和时间。
+func (s *Search) Min(field string) (sql.NullString, error) {
+	var min sql.NullString
+	err := s.aggregate("MIN", false, field, &min)
+	return min, err
+}
+
+//CountDistinct COUNT(DISTINCT field)
+func (s *Search) CountDistinct(field string) (int64, error) {
+	var count int64
+	err := s.aggregate("COUNT", true, field, &count)
+	return count, err
+}
+
+//Exists 是否有符合条件的数据，出错的时候返回false，需要错误的时候使用ExistsErr。
+func (s *Search) Exists() bool {
+	exists, _ := s.ExistsErr()
+	return exists
+}
+
+//ExistsErr 是否有符合条件的数据
+func (s *Search) ExistsErr() (bool, error) {
+	if err := s.check(); err != nil {
+		return false, err
+	}
+	query, args := s.existsQuery()
+	var exists bool
+	if err := s.table.Query(query, args...).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+//existsQuery SELECT EXISTS(...)，有UNION、GROUP BY或者DISTINCT的时候和aggregateQuery一样放到子查询中。
+func (s *Search) existsQuery() (string, []interface{}) {
+	query, args := s.aggregateQuery("1", "1", "")
+	return "SELECT EXISTS(" + query + ")", args
+}
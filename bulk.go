@@ -0,0 +1,346 @@
+package crud
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+//maxPlaceholders MySQL一条预处理语句最多65535个参数
+const maxPlaceholders = 65535
+
+//CreateMany 批量创建，多行放在一条 INSERT ... VALUES (...),(...) 中执行，返回的ID和rows的顺序一致。
+/*
+	所有行的字段取并集，某一行没有的字段使用DEFAULT。
+	每条语句最多Config.BulkSize行，并且不超过65535个参数和max_allowed_packet，超过的时候分成多条语句在同一个事务中执行。
+
+	ID根据每条语句的LastInsertId推算，要求auto_increment_increment为1并且一条语句生成的自增ID是连续的，
+	innodb_autoinc_lock_mode为0、1，或者为2但是用的是这种行数确定的INSERT时成立。
+	行中带了非0的id时返回这个id，这种情况下其他行的ID可能不准确。
+*/
+func (t *Table) CreateMany(rows []map[string]interface{}) ([]int64, error) {
+	ids := []int64{}
+	if len(rows) == 0 {
+		return ids, nil
+	}
+	if t.tableColumns[t.tableName].HaveColumn(CreatedAt) {
+		now := time.Now().Format(TimeFormat)
+		for _, m := range rows {
+			m[CreatedAt] = now
+		}
+	}
+	cols := bulkColumns(rows)
+	head := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES ", t.tableName, strings.Join(cols, ","))
+	chunks := t.bulkChunks(len(head), rows, insertRowCost(cols))
+	if len(chunks) == 1 {
+		return t.insertChunk(head, cols, rows)
+	}
+	err := t.DataBase.Transaction(func(tx *Tx) error {
+		table := tx.Table(t.tableName)
+		for _, chunk := range chunks {
+			chunkIDs, err := table.insertChunk(head, cols, chunk)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, chunkIDs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return []int64{}, err
+	}
+	return ids, nil
+}
+
+//insertChunkQuery 生成一批INSERT语句，某一行没有的字段使用DEFAULT。
+func insertChunkQuery(head string, cols []string, rows []map[string]interface{}) (string, []interface{}) {
+	values := make([]string, 0, len(rows))
+	var args []interface{}
+	for _, m := range rows {
+		holders := make([]string, 0, len(cols))
+		for _, col := range cols {
+			if v, ok := m[strings.Trim(col, "`")]; ok {
+				holders = append(holders, "?")
+				args = append(args, v)
+			} else {
+				holders = append(holders, "DEFAULT")
+			}
+		}
+		values = append(values, "("+strings.Join(holders, ",")+")")
+	}
+	return head + strings.Join(values, ","), args
+}
+
+//insertChunk 执行一条INSERT并推算每一行的ID
+func (t *Table) insertChunk(head string, cols []string, rows []map[string]interface{}) ([]int64, error) {
+	query, args := insertChunkQuery(head, cols, rows)
+	id, err := t.Exec(query, args...).ID()
+	if err != nil {
+		return nil, convertError(err, t.tableName)
+	}
+	ids := make([]int64, 0, len(rows))
+	for _, m := range rows {
+		if given := bulkID(m["id"]); given > 0 {
+			ids = append(ids, given)
+			continue
+		}
+		if id <= 0 {
+			return nil, ErrInsertData
+		}
+		ids = append(ids, id)
+		id++
+	}
+	return ids, nil
+}
+
+//UpdateMany 批量更新，每一批使用一条语句：
+//	UPDATE t SET col = CASE key WHEN ? THEN ? ... ELSE col END, ... WHERE key IN (...)
+/*
+	key为空时使用id，每一行都必须有key，否则返回ErrNoUpdateKey，key和id不会被更新。
+	某一行没有的字段保持原来的值，表中有updated_at的时候会一起更新。
+	分批的规则和CreateMany一样，多批在同一个事务中执行，返回所有批影响行数的和。
+*/
+func (t *Table) UpdateMany(rows []map[string]interface{}, key string) (int64, error) {
+	if key == "" {
+		key = "id"
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	var keys []string
+	for _, m := range rows {
+		if _, ok := m[key]; !ok {
+			return 0, ErrNoUpdateKey
+		}
+		for k := range m {
+			if k != key && k != "id" && k != UpdatedAt {
+				keys = append(keys, k)
+			}
+		}
+	}
+	cols := quoteColumns(keys)
+	if len(cols) == 0 {
+		return 0, ErrArgs
+	}
+	chunks := t.bulkChunks(len(t.tableName)+64, rows, updateRowCost(key, cols))
+	if len(chunks) == 1 {
+		return t.updateChunk(key, cols, rows)
+	}
+	var affected int64
+	err := t.DataBase.Transaction(func(tx *Tx) error {
+		table := tx.Table(t.tableName)
+		for _, chunk := range chunks {
+			n, err := table.updateChunk(key, cols, chunk)
+			if err != nil {
+				return err
+			}
+			affected += n
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+//updateChunkQuery 生成一批UPDATE语句，这一批中都没有的字段不出现在SET中。
+func (t *Table) updateChunkQuery(key string, cols []string, rows []map[string]interface{}) (string, []interface{}) {
+	var (
+		sets []string
+		args []interface{}
+	)
+	quotedKey := "`" + key + "`"
+	for _, col := range cols {
+		var cases []string
+		for _, m := range rows {
+			if v, ok := m[strings.Trim(col, "`")]; ok {
+				cases = append(cases, " WHEN ? THEN ?")
+				args = append(args, m[key], v)
+			}
+		}
+		if len(cases) > 0 {
+			sets = append(sets, col+" = CASE "+quotedKey+strings.Join(cases, "")+" ELSE "+col+" END")
+		}
+	}
+	if len(sets) == 0 {
+		return "", nil
+	}
+	if t.tableColumns[t.tableName].HaveColumn(UpdatedAt) {
+		sets = append(sets, "`"+UpdatedAt+"` = ?")
+		args = append(args, time.Now().Format(TimeFormat))
+	}
+	for _, m := range rows {
+		args = append(args, m[key])
+	}
+	query := fmt.Sprintf("UPDATE `%s` SET %s WHERE %s IN (%s)", t.tableName, strings.Join(sets, ", "), quotedKey, argslice(len(rows)))
+	return query, args
+}
+
+func (t *Table) updateChunk(key string, cols []string, rows []map[string]interface{}) (int64, error) {
+	query, args := t.updateChunkQuery(key, cols, rows)
+	if query == "" {
+		return 0, nil
+	}
+	affected, err := t.Exec(query, args...).RowsAffected()
+	return affected, convertError(err, t.tableName)
+}
+
+//UpdateMany 根据ID批量更新结构体，见Table.UpdateMany，每个结构体都会调用BeforeUpdate、AfterUpdate钩子。
+//所有操作在一个事务中执行，ID为0的时候返回ErrMustNeedID，Omit的字段不更新。
+func (db *DataBase) UpdateMany(objs interface{}) (int64, error) {
+	v := reflect.ValueOf(objs)
+	if v.Kind() != reflect.Ptr {
+		return 0, ErrMustNeedAddr
+	}
+	if v.Elem().Kind() != reflect.Slice {
+		return 0, ErrMustNeedSlice
+	}
+	num := v.Elem().Len()
+	if num == 0 {
+		return 0, nil
+	}
+	tableName := getStructDBName(v.Elem().Index(0).Addr())
+
+	var affected int64
+	err := db.Transaction(func(tx *Tx) error {
+		rows := make([]map[string]interface{}, 0, num)
+		for i := 0; i < num; i++ {
+			elem := v.Elem().Index(i).Addr()
+			if getStructID(elem) == 0 {
+				return ErrMustNeedID
+			}
+			if err := callHook(elem, BeforeUpdate, tx.DataBase); err != nil {
+				return err
+			}
+			rows = append(rows, structToMapFunc(elem, tx.updateFilter(nil)))
+		}
+		n, err := tx.Table(tableName).UpdateMany(rows, "id")
+		if err != nil {
+			return err
+		}
+		for i := 0; i < num; i++ {
+			if err := callHook(v.Elem().Index(i).Addr(), AfterUpdate, tx.DataBase); err != nil {
+				return err
+			}
+		}
+		affected = n
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+//bulkChunks 按行数、参数个数和语句大小把rows分成多批，cost返回一行的参数个数和估算的字节数。
+func (t *Table) bulkChunks(base int, rows []map[string]interface{}, cost func(map[string]interface{}) (int, int)) [][]map[string]interface{} {
+	bulkSize := t.bulkSize
+	if bulkSize <= 0 {
+		bulkSize = DefaultBulkSize
+	}
+	maxPacket := t.maxAllowedPacket
+	if maxPacket <= 0 {
+		maxPacket = DefaultMaxAllowedPacket
+	}
+	var (
+		chunks      [][]map[string]interface{}
+		start, args int
+		size        = base
+	)
+	for i, m := range rows {
+		rowArgs, rowSize := cost(m)
+		if i > start && (i-start >= bulkSize || args+rowArgs > maxPlaceholders || size+rowSize > maxPacket) {
+			chunks = append(chunks, rows[start:i])
+			start, args, size = i, 0, base
+		}
+		args += rowArgs
+		size += rowSize
+	}
+	return append(chunks, rows[start:])
+}
+
+//insertRowCost CreateMany中一行 (?,DEFAULT,...) 的参数个数和字节数
+func insertRowCost(cols []string) func(map[string]interface{}) (int, int) {
+	return func(m map[string]interface{}) (int, int) {
+		args, size := 0, 3
+		for _, col := range cols {
+			if v, ok := m[strings.Trim(col, "`")]; ok {
+				args++
+				size += 2 + valueSize(v)
+			} else {
+				size += len("DEFAULT,")
+			}
+		}
+		return args, size
+	}
+}
+
+//updateRowCost UpdateMany中一行的参数个数和字节数，每个字段一个 WHEN ? THEN ?，再加上IN中的key。
+func updateRowCost(key string, cols []string) func(map[string]interface{}) (int, int) {
+	return func(m map[string]interface{}) (int, int) {
+		keySize := valueSize(m[key])
+		args, size := 1, keySize+1
+		for _, col := range cols {
+			if v, ok := m[strings.Trim(col, "`")]; ok {
+				args += 2
+				size += keySize + valueSize(v) + len(" WHEN ? THEN ?")
+			}
+		}
+		return args, size
+	}
+}
+
+//bulkColumns 所有行的字段的并集
+func bulkColumns(rows []map[string]interface{}) []string {
+	var keys []string
+	for _, m := range rows {
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	return quoteColumns(keys)
+}
+
+//quoteColumns 去重、排序后加上`，排序保证每次生成的语句一样。
+func quoteColumns(keys []string) []string {
+	seen := map[string]bool{}
+	cols := []string{}
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			cols = append(cols, k)
+		}
+	}
+	sort.Strings(cols)
+	for i, col := range cols {
+		cols[i] = "`" + col + "`"
+	}
+	return cols
+}
+
+//valueSize 估算参数在语句中的字节数，字符串转义后可能变长，按两倍算。
+func valueSize(v interface{}) int {
+	switch x := v.(type) {
+	case string:
+		return 2*len(x) + 2
+	case []byte:
+		return 2*len(x) + 2
+	case nil:
+		return 4
+	}
+	return 24
+}
+
+func bulkID(v interface{}) int64 {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	}
+	return 0
+}
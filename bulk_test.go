@@ -0,0 +1,113 @@
+package crud
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBulkChunks(t *testing.T) {
+	db := newTestDataBase()
+	db.bulkSize = 2
+	table := db.Table("task")
+	rows := []map[string]interface{}{
+		{"name": "a", "state": 1},
+		{"name": "b"},
+		{"state": 3},
+	}
+	cols := bulkColumns(rows)
+	if !reflect.DeepEqual(cols, []string{"`name`", "`state`"}) {
+		t.Fatalf("columns = %v", cols)
+	}
+	chunks := table.bulkChunks(0, rows, insertRowCost(cols))
+	if len(chunks) != 2 || len(chunks[0]) != 2 || len(chunks[1]) != 1 {
+		t.Fatalf("chunks = %v", chunks)
+	}
+	query, args := insertChunkQuery("INSERT INTO `task` (`name`,`state`) VALUES ", cols, rows)
+	if query != "INSERT INTO `task` (`name`,`state`) VALUES (?,?),(?,DEFAULT),(DEFAULT,?)" || !reflect.DeepEqual(args, []interface{}{"a", 1, "b", 3}) {
+		t.Errorf("insert %s %v", query, args)
+	}
+}
+
+// wideRows n行相同的数据，每行1000个字段
+func wideRows(n int) []map[string]interface{} {
+	row := map[string]interface{}{"id": 1}
+	for i := 0; i < 1000; i++ {
+		row[string(rune('a'+i%26))+string(rune('a'+i/26))] = i
+	}
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = row
+	}
+	return rows
+}
+
+func TestBulkChunksLimits(t *testing.T) {
+	db := newTestDataBase()
+	db.bulkSize = 100000
+	table := db.Table("task")
+	rows := wideRows(100)
+	cols := bulkColumns(rows)
+	chunks := table.bulkChunks(0, rows, insertRowCost(cols))
+	if len(chunks) < 2 {
+		t.Errorf("len(chunks) = %d, want more than 1 for placeholders", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if _, args := insertChunkQuery("", cols, chunk); len(args) > maxPlaceholders {
+			t.Errorf("chunk has %d placeholders", len(args))
+		}
+	}
+
+	db.maxAllowedPacket = 1024
+	big := []map[string]interface{}{{"name": string(make([]byte, 400))}, {"name": string(make([]byte, 400))}}
+	if chunks := table.bulkChunks(0, big, insertRowCost(bulkColumns(big))); len(chunks) != 2 {
+		t.Errorf("len(chunks) = %d, want 2 for max_allowed_packet", len(chunks))
+	}
+}
+
+func TestUpdateChunksLimits(t *testing.T) {
+	db := newTestDataBase()
+	db.bulkSize = 100000
+	table := db.Table("task")
+	rows := wideRows(100)
+	var keys []string
+	for k := range rows[0] {
+		if k != "id" {
+			keys = append(keys, k)
+		}
+	}
+	cols := quoteColumns(keys)
+	chunks := table.bulkChunks(0, rows, updateRowCost("id", cols))
+	if len(chunks) < 2 {
+		t.Errorf("len(chunks) = %d, want more than 1 for placeholders", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if _, args := table.updateChunkQuery("id", cols, chunk); len(args) > maxPlaceholders {
+			t.Errorf("chunk has %d placeholders", len(args))
+		}
+	}
+
+	db.maxAllowedPacket = 1024
+	big := []map[string]interface{}{{"id": 1, "name": string(make([]byte, 400))}, {"id": 2, "name": string(make([]byte, 400))}}
+	if chunks := table.bulkChunks(0, big, updateRowCost("id", []string{"`name`"})); len(chunks) != 2 {
+		t.Errorf("len(chunks) = %d, want 2 for max_allowed_packet", len(chunks))
+	}
+}
+
+func TestUpdateChunkQuery(t *testing.T) {
+	table := newTestDataBase().Table("task")
+	rows := []map[string]interface{}{
+		{"id": 1, "name": "a", "state": 1},
+		{"id": 2, "state": 2},
+	}
+	query, args := table.updateChunkQuery("id", []string{"`name`", "`state`"}, rows)
+	want := "UPDATE `task` SET `name` = CASE `id` WHEN ? THEN ? ELSE `name` END, `state` = CASE `id` WHEN ? THEN ? WHEN ? THEN ? ELSE `state` END WHERE `id` IN (?,?)"
+	if query != want {
+		t.Errorf("query\n got: %s\nwant: %s", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1, "a", 1, 1, 2, 2, 1, 2}) {
+		t.Errorf("args = %v", args)
+	}
+	if _, err := table.UpdateMany([]map[string]interface{}{{"name": "a"}}, ""); err != ErrNoUpdateKey {
+		t.Errorf("err = %v, want ErrNoUpdateKey", err)
+	}
+}
@@ -1,16 +1,196 @@
 package crud
 
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// 连接池的默认值，和之前NewDataBase中写死的保持一致。
+const (
+	DefaultMaxIdleConns = 20
+	DefaultMaxOpenConns = 20
+
+	DefaultBulkSize         = 500     //CreateMany每条INSERT最多插入的行数
+	DefaultMaxAllowedPacket = 4 << 20 //MySQL5.7 max_allowed_packet的默认值
+)
+
+// 配置相关的错误
+var (
+	ErrEmptyDSN          = errors.New("DataSourceName不能为空")
+	ErrNoConnection      = errors.New("没有数据库连接(IsJoke)")
+	ErrConfigFileType    = errors.New("不支持的配置文件类型")
+	ErrConfigEnvNotFound = errors.New("环境变量中没有对应的配置")
+)
+
 //Config 用于创建连接的配置配置
 type Config struct {
-	DataSourceName string
-	MaxIdleConns   int
-	MaxOpenConns   int
-	Render         Render
-	isRender       bool
+	DataSourceName   string
+	MaxIdleConns     int           //最大空闲连接数，为0时使用DefaultMaxIdleConns，小于0时不保留空闲连接。
+	MaxOpenConns     int           //最大打开连接数，为0时使用DefaultMaxOpenConns，小于0时不限制。
+	ConnMaxLifetime  time.Duration //连接最长可复用时间，为0时不限制。
+	ConnMaxIdleTime  time.Duration //连接最长空闲时间，为0时不限制。
+	BulkSize         int           //批量插入时每条语句最多的行数，为0时使用DefaultBulkSize。
+	MaxAllowedPacket int           //批量插入时每条语句最大的字节数，为0时使用DSN中的maxAllowedPacket，DSN中也没有时使用DefaultMaxAllowedPacket。
+	UpsertAlias      bool          //Upsert使用MySQL8.0.19之后的 INSERT ... AS alias 代替已经废弃的VALUES()。
+	Render           Render
+	isRender         bool
+
+	IsJoke bool //是否是玩笑，如果是玩笑返回一个没有连接的DataBase，所有查询都返回ErrNoConnection。用于多个项目共用同一份配置文件，但是有些数据库不需要加载。
+}
+
+// parse 填充默认值并检查配置是否正确
+func (config *Config) parse() error {
+	config.isRender = config.Render != nil
+	if config.IsJoke {
+		return nil
+	}
+	if config.DataSourceName == "" {
+		return ErrEmptyDSN
+	}
+	dsn, err := mysql.ParseDSN(config.DataSourceName)
+	if err != nil {
+		return err
+	}
+	if config.BulkSize == 0 {
+		config.BulkSize = DefaultBulkSize
+	}
+	if config.MaxAllowedPacket == 0 {
+		config.MaxAllowedPacket = dsn.MaxAllowedPacket
+	}
+	if config.MaxAllowedPacket <= 0 {
+		config.MaxAllowedPacket = DefaultMaxAllowedPacket
+	}
+	if config.MaxIdleConns == 0 {
+		config.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if config.MaxOpenConns == 0 {
+		config.MaxOpenConns = DefaultMaxOpenConns
+	}
+	return nil
+}
 
-	IsJoke bool //是否是玩笑，如果是玩笑直接返回nil,nil。用于多个项目共用同一份配置文件，但是有些数据库不需要加载。
+// fileConfig 是配置文件中的格式，时间使用"30s"、"1h"这样的字符串表示。
+type fileConfig struct {
+	DataSourceName   string `json:"data_source_name" yaml:"data_source_name"`
+	MaxIdleConns     int    `json:"max_idle_conns" yaml:"max_idle_conns"`
+	MaxOpenConns     int    `json:"max_open_conns" yaml:"max_open_conns"`
+	BulkSize         int    `json:"bulk_size" yaml:"bulk_size"`
+	MaxAllowedPacket int    `json:"max_allowed_packet" yaml:"max_allowed_packet"`
+	UpsertAlias      bool   `json:"upsert_alias" yaml:"upsert_alias"`
+	ConnMaxLifetime  string `json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime  string `json:"conn_max_idle_time" yaml:"conn_max_idle_time"`
+	IsJoke           bool   `json:"is_joke" yaml:"is_joke"`
 }
 
-func (config *Config) parse() {
+func (fc fileConfig) config() (Config, error) {
+	config := Config{
+		DataSourceName:   fc.DataSourceName,
+		MaxIdleConns:     fc.MaxIdleConns,
+		MaxOpenConns:     fc.MaxOpenConns,
+		BulkSize:         fc.BulkSize,
+		MaxAllowedPacket: fc.MaxAllowedPacket,
+		UpsertAlias:      fc.UpsertAlias,
+		IsJoke:           fc.IsJoke,
+	}
+	var err error
+	if config.ConnMaxLifetime, err = parseDuration(fc.ConnMaxLifetime); err != nil {
+		return config, err
+	}
+	if config.ConnMaxIdleTime, err = parseDuration(fc.ConnMaxIdleTime); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ParseConfigJSON 从JSON中解析配置
+func ParseConfigJSON(data []byte) (Config, error) {
+	var fc fileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return Config{}, err
+	}
+	return fc.config()
+}
+
+// ParseConfigYAML 从YAML中解析配置
+func ParseConfigYAML(data []byte) (Config, error) {
+	var fc fileConfig
+	if err := yaml.Unmarshal(data, &fc); err != nil {
+		return Config{}, err
+	}
+	return fc.config()
+}
+
+// LoadConfig 根据文件后缀(.json/.yaml/.yml)读取配置文件
+func LoadConfig(filename string) (Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Config{}, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return ParseConfigJSON(data)
+	case ".yaml", ".yml":
+		return ParseConfigYAML(data)
+	}
+	return Config{}, ErrConfigFileType
+}
 
+// ConfigFromEnv 从环境变量中读取配置，prefix为MYSQL时读取：
+// MYSQL_DSN MYSQL_MAX_IDLE_CONNS MYSQL_MAX_OPEN_CONNS MYSQL_CONN_MAX_LIFETIME MYSQL_CONN_MAX_IDLE_TIME MYSQL_BULK_SIZE MYSQL_MAX_ALLOWED_PACKET MYSQL_IS_JOKE
+func ConfigFromEnv(prefix string) (Config, error) {
+	var (
+		fc  fileConfig
+		err error
+	)
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	fc.DataSourceName = os.Getenv(prefix + "DSN")
+	if v := os.Getenv(prefix + "MAX_IDLE_CONNS"); v != "" {
+		if fc.MaxIdleConns, err = strconv.Atoi(v); err != nil {
+			return Config{}, err
+		}
+	}
+	if v := os.Getenv(prefix + "MAX_OPEN_CONNS"); v != "" {
+		if fc.MaxOpenConns, err = strconv.Atoi(v); err != nil {
+			return Config{}, err
+		}
+	}
+	if v := os.Getenv(prefix + "BULK_SIZE"); v != "" {
+		if fc.BulkSize, err = strconv.Atoi(v); err != nil {
+			return Config{}, err
+		}
+	}
+	if v := os.Getenv(prefix + "MAX_ALLOWED_PACKET"); v != "" {
+		if fc.MaxAllowedPacket, err = strconv.Atoi(v); err != nil {
+			return Config{}, err
+		}
+	}
+	if v := os.Getenv(prefix + "IS_JOKE"); v != "" {
+		if fc.IsJoke, err = strconv.ParseBool(v); err != nil {
+			return Config{}, err
+		}
+	}
+	fc.ConnMaxLifetime = os.Getenv(prefix + "CONN_MAX_LIFETIME")
+	fc.ConnMaxIdleTime = os.Getenv(prefix + "CONN_MAX_IDLE_TIME")
+	if fc.DataSourceName == "" && !fc.IsJoke {
+		return Config{}, ErrConfigEnvNotFound
+	}
+	return fc.config()
 }
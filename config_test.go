@@ -0,0 +1,77 @@
+package crud
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseConfigJSON(t *testing.T) {
+	config, err := ParseConfigJSON([]byte(`{"data_source_name":"root:@/demo","max_open_conns":50,"conn_max_lifetime":"1h"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.DataSourceName != "root:@/demo" || config.MaxOpenConns != 50 || config.ConnMaxLifetime != time.Hour {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if err := config.parse(); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxIdleConns != DefaultMaxIdleConns {
+		t.Fatalf("MaxIdleConns = %d, want %d", config.MaxIdleConns, DefaultMaxIdleConns)
+	}
+	if config.BulkSize != DefaultBulkSize || config.MaxAllowedPacket <= 0 {
+		t.Fatalf("BulkSize = %d, MaxAllowedPacket = %d", config.BulkSize, config.MaxAllowedPacket)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	os.Setenv("CRUD_TEST_IS_JOKE", "true")
+	defer os.Unsetenv("CRUD_TEST_IS_JOKE")
+	config, err := ConfigFromEnv("CRUD_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDataBaseWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !db.IsJoke() {
+		t.Fatal("want joke database")
+	}
+	if err := db.Query("SELECT 1").err; err != ErrNoConnection {
+		t.Fatalf("err = %v, want ErrNoConnection", err)
+	}
+}
+
+func TestConfigMaxAllowedPacket(t *testing.T) {
+	config, err := ParseConfigJSON([]byte(`{"data_source_name":"root:@/demo","bulk_size":100,"max_allowed_packet":1048576}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BulkSize != 100 || config.MaxAllowedPacket != 1<<20 {
+		t.Fatalf("json BulkSize = %d, MaxAllowedPacket = %d", config.BulkSize, config.MaxAllowedPacket)
+	}
+	config, err = ParseConfigYAML([]byte("data_source_name: root:@/demo\nmax_allowed_packet: 2097152\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxAllowedPacket != 2<<20 {
+		t.Fatalf("yaml MaxAllowedPacket = %d", config.MaxAllowedPacket)
+	}
+
+	os.Setenv("CRUD_TEST_DSN", "root:@/demo")
+	os.Setenv("CRUD_TEST_MAX_ALLOWED_PACKET", "65536")
+	defer os.Unsetenv("CRUD_TEST_DSN")
+	defer os.Unsetenv("CRUD_TEST_MAX_ALLOWED_PACKET")
+	if config, err = ConfigFromEnv("CRUD_TEST"); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxAllowedPacket != 65536 {
+		t.Fatalf("env MaxAllowedPacket = %d", config.MaxAllowedPacket)
+	}
+	os.Setenv("CRUD_TEST_MAX_ALLOWED_PACKET", "4M")
+	if _, err := ConfigFromEnv("CRUD_TEST"); err == nil {
+		t.Fatal("want error for invalid MAX_ALLOWED_PACKET")
+	}
+}
@@ -0,0 +1,52 @@
+package crud
+
+import (
+	"context"
+	"testing"
+)
+
+type ctxKey struct{}
+
+func TestWithContextPropagation(t *testing.T) {
+	db, _ := newStubDataBase(t)
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	ctxDB := db.WithContext(ctx)
+	if db.Context() == ctx {
+		t.Error("WithContext should not change the original DataBase")
+	}
+
+	table := ctxDB.Table("task").Where("state = ?", 1).OrderDesc("id")
+	for name, s := range map[string]*Search{
+		"chain":  table.Search,
+		"clone":  table.Search.Clone(),
+		"search": table.Search.Eq("name", "a").Limit(1),
+		"table":  table.Clone().Search,
+	} {
+		if s.table.Context() != ctx {
+			t.Errorf("%s lost the context", name)
+		}
+	}
+
+	other := context.WithValue(context.Background(), ctxKey{}, "other")
+	if s := table.Search.WithContext(other); s.table.Context() != other || table.Search.table.Context() != ctx {
+		t.Error("Search.WithContext should only change the returned Search")
+	}
+
+	tx, err := ctxDB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if tx.Context() != ctx || tx.Table("task").Where("state = ?", 1).Search.table.Context() != ctx {
+		t.Error("Tx should keep the context of the DataBase it was started from")
+	}
+	if s := tx.Table("task").WithContext(other).Search; s.table.Context() != other || s.table.tx != tx {
+		t.Error("Table.WithContext in a Tx should keep the transaction")
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := db.WithContext(canceled).Table("task").Where("state = ?", 1).Rows().Err(); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -1,6 +1,7 @@
 package crud
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -10,7 +11,7 @@ import (
 	"runtime"
 	"strings"
 
-	_ "github.com/go-sql-driver/mysql" //
+	_ "github.com/go-sql-driver/mysql" //注册mysql驱动
 )
 
 // 变量
@@ -21,15 +22,20 @@ var (
 	ErrExec = errors.New("执行错误")
 	ErrArgs = errors.New("参数错误")
 
-	ErrInsertRepeat = errors.New("重复插入")
+	ErrInsertRepeat = errors.New("重复插入") //唯一键冲突，MySQL 1062也会转换成这个错误
 	ErrSQLSyncPanic = errors.New("SQL语句异常")
 	ErrInsertData   = errors.New("插入数据库异常")
 	ErrNoUpdateKey  = errors.New("没有更新主键")
+	ErrNoCondition  = errors.New("没有条件，不能更新或删除整张表")
+	ErrCursor       = errors.New("游标错误")
+
+	ErrNeedTransaction = errors.New("FOR UPDATE、FOR SHARE必须在事务中使用")
 
 	ErrMustNeedAddr   = errors.New("必须为值引用")
 	ErrMustNeedSlice  = errors.New("必须为Slice")
 	ErrMustNeedID     = errors.New("必须要有ID")
 	ErrNotSupportType = errors.New("不支持类型")
+	ErrHookSignature  = errors.New("钩子函数签名错误")
 )
 
 // Render 用于对接http.HandleFunc直接调用CRUD
@@ -41,37 +47,70 @@ type DataBase struct {
 	tableColumns   map[string]Columns
 	dataSourceName string
 	db             *sql.DB
+	tx             *Tx             //不为nil时所有语句都在这个事务中执行
+	ctx            context.Context //所有语句都使用这个context执行，为nil时使用context.Background()
+	scopes         *scopeRegistry  //RegisterScope注册的scope，WithContext、Begin复制出来的DataBase共用
+
+	bulkSize         int  //CreateMany每条语句最多的行数
+	maxAllowedPacket int  //CreateMany每条语句最大的字节数
+	upsertAlias      bool //Upsert使用 INSERT ... AS alias
+
+	omits []string //Omit设置的更新时忽略的字段
 
 	render Render //crud本身不渲染数据，通过其他地方传入一个渲染的函数，然后渲染都是那边处理。
 }
 
 // NewDataBase 创建一个新的数据库链接
 func NewDataBase(dataSourceName string, render ...Render) (*DataBase, error) {
-	db, err := sql.Open("mysql", dataSourceName)
-	if err != nil {
+	config := Config{DataSourceName: dataSourceName}
+	if len(render) == 1 {
+		config.Render = render[0]
+	}
+	return NewDataBaseWithConfig(config)
+}
+
+// NewDataBaseWithConfig 根据配置创建一个新的数据库链接
+func NewDataBaseWithConfig(config Config) (*DataBase, error) {
+	if err := config.parse(); err != nil {
 		return nil, err
 	}
-	db.SetMaxIdleConns(20)
-	db.SetMaxOpenConns(20)
 	crud := &DataBase{
-		debug:          false,
-		tableColumns:   make(map[string]Columns),
-		dataSourceName: dataSourceName,
-		db:             db,
+		debug:            false,
+		tableColumns:     make(map[string]Columns),
+		dataSourceName:   config.DataSourceName,
+		scopes:           newScopeRegistry(),
+		bulkSize:         config.BulkSize,
+		maxAllowedPacket: config.MaxAllowedPacket,
+		upsertAlias:      config.UpsertAlias,
 		render: func(w http.ResponseWriter, err error, data ...interface{}) {
-			if len(render) == 1 {
-				if render[0] != nil {
-					render[0](w, err, data...)
-				}
+			if config.isRender {
+				config.Render(w, err, data...)
 			}
 		},
 	}
+	if config.IsJoke {
+		return crud, nil
+	}
 
-	for _, tableMap := range crud.Query("SHOW TABLES").RowsMap() {
+	db, err := sql.Open("mysql", config.DataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	crud.db = db
+
+	tables := crud.Query("SHOW TABLES")
+	for _, tableMap := range tables.RowsMap() {
 		for _, table := range tableMap {
 			crud.getColumns(table)
 		}
 	}
+	if err := tables.Err(); err != nil {
+		return nil, err
+	}
 	return crud, nil
 }
 
@@ -203,29 +242,71 @@ func (db *DataBase) RowSQL(sql string, args ...interface{}) *SQLRows {
 // Query 用于底层查询，一般是SELECT语句
 func (db *DataBase) Query(sql string, args ...interface{}) *SQLRows {
 	db.LogSQL(sql, args...)
-	rows, err := db.DB().Query(sql, args...)
+	if db.db == nil {
+		return &SQLRows{err: ErrNoConnection}
+	}
+	rows, err := db.executor().QueryContext(db.context(), sql, args...)
 
 	if err != nil {
 		db.stack(err, sql, args...)
 	}
-	return &SQLRows{rows: rows, err: err}
+	return &SQLRows{rows: rows, err: convertError(err, "")}
 }
 
 // Exec 用于底层执行，一般是INSERT INTO、DELETE、UPDATE。
-func (db *DataBase) Exec(sql string, args ...interface{}) sql.Result {
+// 出错的时候返回的SQLResult也不为nil，错误在ID、Effected(LastInsertId、RowsAffected)中返回。
+func (db *DataBase) Exec(sql string, args ...interface{}) *SQLResult {
 	db.LogSQL(sql, args...)
-	ret, err := db.DB().Exec(sql, args...)
+	if db.db == nil {
+		return &SQLResult{err: ErrNoConnection}
+	}
+	ret, err := db.executor().ExecContext(db.context(), sql, args...)
 	if err != nil {
 		db.stack(err, sql, args...)
 	}
-	return ret
+	return &SQLResult{ret: ret, err: convertError(err, "")}
 }
 
 // DB 返回一个DB链接，查询后一定要关闭col，而不能关闭*sql.DB。
+// IsJoke的DataBase返回nil。
 func (db *DataBase) DB() *sql.DB {
 	return db.db
 }
 
+// executor 如果在事务中则返回*sql.Tx，否则返回*sql.DB。
+func (db *DataBase) executor() executor {
+	if db.tx != nil {
+		return db.tx.tx
+	}
+	return db.db
+}
+
+// WithContext 返回一个使用ctx执行所有语句的DataBase，原来的DataBase不受影响。
+// ctx取消或者超时的时候MySQL的查询也会被取消。
+func (db *DataBase) WithContext(ctx context.Context) *DataBase {
+	clone := *db
+	clone.ctx = ctx
+	return &clone
+}
+
+// Context 返回当前使用的context
+func (db *DataBase) Context() context.Context {
+	return db.context()
+}
+
+func (db *DataBase) context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
+}
+
+// IsJoke 是否是没有连接的DataBase
+func (db *DataBase) IsJoke() bool {
+	return db.db == nil
+}
+
+
 //Create 根据相应单个结构体进行创建
 func (db *DataBase) Create(obj interface{}) (int64, error) {
 	//一定要是地址
@@ -237,36 +318,29 @@ func (db *DataBase) Create(obj interface{}) (int64, error) {
 	if v.Kind() != reflect.Ptr {
 		return 0, ErrMustNeedAddr
 	}
-	beforeFunc := v.MethodByName(BeforeCreate)
-	afterFunc := v.MethodByName(AfterCreate)
 	tableName := getStructDBName(v)
 
 	// 这里的处理应该是有才处理，没有不管。
-	if beforeFunc.IsValid() {
-		vals := beforeFunc.Call(nil)
-		if len(vals) == 1 {
-			if err, ok := vals[0].Interface().(error); ok {
-				if err != nil {
-					return 0, err
-				}
-			}
-		}
+	if err := callHook(v, BeforeCreate, db); err != nil {
+		return 0, err
 	}
 	m := structToMap(v)
 	id, err := db.Table(tableName).Create(m)
+	if err != nil {
+		return 0, err
+	}
 
 	rID := v.Elem().FieldByName("ID")
 	if rID.IsValid() {
 		rID.SetInt(id)
 	}
 
-	if afterFunc.IsValid() {
-		afterFunc.Call(nil)
-	}
-	return id, err
+	return id, callHook(v, AfterCreate, db)
 }
 
 //Creates 根据相应多个结构体进行创建
+//使用Table.CreateMany批量插入，每个结构体的钩子仍然会调用，ID会回填到结构体中。
+//所有操作在一个事务中执行，任何一个钩子返回错误都会回滚。
 func (db *DataBase) Creates(objs interface{}) ([]int64, error) {
 	ids := []int64{}
 	v := reflect.ValueOf(objs)
@@ -276,42 +350,144 @@ func (db *DataBase) Creates(objs interface{}) ([]int64, error) {
 	if v.Elem().Kind() != reflect.Slice {
 		return ids, ErrMustNeedSlice
 	}
+	num := v.Elem().Len()
+	if num == 0 {
+		return ids, nil
+	}
+	tableName := getStructDBName(v.Elem().Index(0).Addr())
 
-	for i, num := 0, v.Elem().Len(); i < num; i++ {
-		id, err := db.Create(v.Elem().Index(i).Addr().Interface())
+	err := db.Transaction(func(tx *Tx) error {
+		rows := make([]map[string]interface{}, 0, num)
+		for i := 0; i < num; i++ {
+			elem := v.Elem().Index(i).Addr()
+			if err := callHook(elem, BeforeCreate, tx.DataBase); err != nil {
+				return err
+			}
+			rows = append(rows, structToMap(elem))
+		}
+		created, err := tx.Table(tableName).CreateMany(rows)
 		if err != nil {
-			return ids, err
+			return err
 		}
-
-		ids = append(ids, id)
+		for i, id := range created {
+			elem := v.Elem().Index(i).Addr()
+			if rID := elem.Elem().FieldByName("ID"); rID.IsValid() {
+				rID.SetInt(id)
+			}
+			if err := callHook(elem, AfterCreate, tx.DataBase); err != nil {
+				return err
+			}
+		}
+		ids = created
+		return nil
+	})
+	if err != nil {
+		return []int64{}, err
 	}
-
 	return ids, nil
 }
 
 //Update Update
+//更新结构体中的所有字段，只更新部分字段使用UpdateFields、UpdateNonZero或者Omit。
 func (db *DataBase) Update(obj interface{}) error {
-	//根据ID进行Update
+	return db.update(obj, nil)
+}
+
+//UpdateFields 只更新names中的字段，names可以是结构体的字段名或者数据库中的字段名。
+//names中有不存在或者被Omit的字段时返回ErrArgs。
+func (db *DataBase) UpdateFields(obj interface{}, names ...string) error {
+	if len(names) == 0 {
+		return ErrArgs
+	}
+	found := make(map[string]bool, len(names))
+	for _, name := range names {
+		found[name] = false
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		structToMapFunc(v, func(field reflect.StructField, dbName string, _ reflect.Value) bool {
+			for _, name := range []string{field.Name, dbName} {
+				if _, ok := found[name]; ok {
+					//同时被Omit的字段不会更新，当作不存在
+					found[name] = !db.omitted(field, dbName)
+				}
+			}
+			return false
+		})
+		for _, ok := range found {
+			if !ok {
+				return ErrArgs
+			}
+		}
+	}
+	return db.update(obj, func(field reflect.StructField, dbName string, _ reflect.Value) bool {
+		_, byField := found[field.Name]
+		_, byDBName := found[dbName]
+		return byField || byDBName
+	})
+}
+
+//UpdateNonZero 只更新不是零值的字段，用于只修改客户端传过来的字段。
+//需要把字段改成零值的时候使用UpdateFields，零值判断在BeforeUpdate之后，钩子中设置的字段也会更新。
+func (db *DataBase) UpdateNonZero(obj interface{}) error {
+	return db.update(obj, func(_ reflect.StructField, _ string, value reflect.Value) bool {
+		return !isBlank(value)
+	})
+}
+
+//Omit 返回一个更新时忽略fields的DataBase，fields可以是结构体的字段名或者数据库中的字段名。
+//	db.Omit("password").Update(&member)
+func (db *DataBase) Omit(fields ...string) *DataBase {
+	clone := *db
+	clone.omits = append(append([]string{}, db.omits...), fields...)
+	return &clone
+}
+
+//updateFilter 在keep的基础上去掉Omit的字段，id一直保留用于更新的条件。
+func (db *DataBase) updateFilter(keep func(field reflect.StructField, dbName string, value reflect.Value) bool) func(reflect.StructField, string, reflect.Value) bool {
+	return func(field reflect.StructField, dbName string, value reflect.Value) bool {
+		if dbName == "id" {
+			return true
+		}
+		if db.omitted(field, dbName) {
+			return false
+		}
+		return keep == nil || keep(field, dbName, value)
+	}
+}
+
+//omitted 字段是否被Omit
+func (db *DataBase) omitted(field reflect.StructField, dbName string) bool {
+	for _, omit := range db.omits {
+		if omit == field.Name || omit == dbName {
+			return true
+		}
+	}
+	return false
+}
+
+//update 根据ID更新keep返回true的字段，keep为nil时更新所有字段，Omit的字段不更新。
+//BeforeUpdate之后没有要更新的字段时返回ErrArgs。
+func (db *DataBase) update(obj interface{}, keep func(field reflect.StructField, dbName string, value reflect.Value) bool) error {
 	v := reflect.ValueOf(obj)
 	if v.Kind() != reflect.Ptr {
 		return ErrMustNeedAddr
 	}
-	beforeFunc := v.MethodByName(BeforeUpdate)
-	afterFunc := v.MethodByName(AfterUpdate)
-	if beforeFunc.IsValid() {
-		beforeFunc.Call(nil)
+	//先调用钩子再取字段，钩子中设置的字段（比如操作人）也会更新
+	if err := callHook(v, BeforeUpdate, db); err != nil {
+		return err
 	}
 	tableName := getStructDBName(v)
-	m := structToMap(v)
+	m := structToMapFunc(v, db.updateFilter(keep))
+	if len(m) <= 1 {
+		return ErrArgs
+	}
 	err := db.Table(tableName).Update(m)
 
 	if err != nil {
 		return err
 	}
-	if afterFunc.IsValid() {
-		afterFunc.Call(nil)
-	}
-	return nil
+	return callHook(v, AfterUpdate, db)
 }
 
 //Updates Updates
@@ -339,10 +515,8 @@ func (db *DataBase) Delete(obj interface{}) (int64, error) {
 	if v.Kind() != reflect.Ptr {
 		return 0, ErrMustNeedAddr
 	}
-	beforeFunc := v.MethodByName(BeforeDelete)
-	afterFunc := v.MethodByName(AfterDelete)
-	if beforeFunc.IsValid() {
-		beforeFunc.Call(nil)
+	if err := callHook(v, BeforeDelete, db); err != nil {
+		return 0, err
 	}
 	id := getStructID(v)
 	if id == 0 {
@@ -351,10 +525,10 @@ func (db *DataBase) Delete(obj interface{}) (int64, error) {
 	tableName := getStructDBName(v)
 
 	count, err := db.Table(tableName).Delete(map[string]interface{}{"id": id})
-	if afterFunc.IsValid() {
-		afterFunc.Call(nil)
+	if err != nil {
+		return count, err
 	}
-	return count, err
+	return count, callHook(v, AfterDelete, db)
 }
 
 //Deletes Deletes
@@ -380,6 +554,7 @@ func (db *DataBase) Deletes(objs interface{}) (int64, error) {
 
 // FormCreate 创建，表单创建。
 func (db *DataBase) FormCreate(v interface{}, w http.ResponseWriter, r *http.Request) {
+	db = db.WithContext(r.Context())
 	tableName := getStructDBName(reflect.ValueOf(v))
 	m := parseRequest(v, r, C)
 	if m == nil || len(m) == 0 {
@@ -403,8 +578,11 @@ func (db *DataBase) FormCreate(v interface{}, w http.ResponseWriter, r *http.Req
 	id = 1  AND hospital_id = 1
 
 	CRUD FormRead -> table Read
+
+	scope=active&scope=today 使用RegisterScope注册过的scope，这时条件通过Search执行，字段不存在或scope不存在返回参数错误。
 */
 func (db *DataBase) FormRead(v interface{}, w http.ResponseWriter, r *http.Request) {
+	db = db.WithContext(r.Context())
 	//	这里传进来的参数一定是要有用的参数，如果是没有用的参数被传进来了，那么会报参数错误，或者显示执行成功数据会乱。
 	//	这里处理last_XXX
 	//	处理翻页的问题
@@ -412,12 +590,32 @@ func (db *DataBase) FormRead(v interface{}, w http.ResponseWriter, r *http.Reque
 	m := parseRequest(v, r, R)
 
 	tableName := getStructDBName(reflect.ValueOf(v))
-	data := db.Table(tableName).Reads(m)
+	scopes := r.Form["scope"]
+	if len(scopes) == 0 {
+		data := db.Table(tableName).Reads(m)
+		db.dataRender(w, data)
+		return
+	}
+	search := db.Table(tableName).Search.Scope(scopes...)
+	for k, v := range m {
+		search = search.Eq(k, v)
+	}
+	rows := search.Rows()
+	data := rows.RowsMap()
+	if err := rows.Err(); err != nil {
+		if errors.Is(err, ErrUnknownScope) || errors.Is(err, ErrUnknownColumn) {
+			db.argsErrorRender(w)
+		} else {
+			db.execErrorRender(w)
+		}
+		return
+	}
 	db.dataRender(w, data)
 }
 
 // FormUpdate 表单更新
 func (db *DataBase) FormUpdate(v interface{}, w http.ResponseWriter, r *http.Request) {
+	db = db.WithContext(r.Context())
 	tableName := getStructDBName(reflect.ValueOf(v))
 	m := parseRequest(v, r, R)
 	if m == nil || len(m) == 0 {
@@ -434,6 +632,7 @@ func (db *DataBase) FormUpdate(v interface{}, w http.ResponseWriter, r *http.Req
 
 // FormDelete 表单删除
 func (db *DataBase) FormDelete(v interface{}, w http.ResponseWriter, r *http.Request) {
+	db = db.WithContext(r.Context())
 	tableName := getStructDBName(reflect.ValueOf(v))
 	m := parseRequest(v, r, R)
 	if m == nil || len(m) == 0 {
@@ -520,16 +719,13 @@ func (db *DataBase) Find(obj interface{}, args ...interface{}) error {
 	switch elem.Kind() {
 	case reflect.Slice:
 		for i, num := 0, elem.Len(); i < num; i++ {
-			afterFunc := elem.Index(i).Addr().MethodByName("AfterFind")
-			if !afterFunc.IsValid() {
-				return nil
+			if err := callHook(elem.Index(i).Addr(), AfterFind, db); err != nil {
+				return err
 			}
-			afterFunc.Call(nil)
 		}
 	case reflect.Struct:
-		afterFunc := v.MethodByName("AfterFind")
-		if afterFunc.IsValid() {
-			afterFunc.Call(nil)
+		if err := callHook(v, AfterFind, db); err != nil {
+			return err
 		}
 	}
 
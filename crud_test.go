@@ -1,6 +1,8 @@
 package crud
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 	// "github.com/jinzhu/gorm"
@@ -124,3 +126,65 @@ func TestNewCRUD(t *testing.T) {
 	// fmt.Println(crud.tableNames)
 	//crud.Create(&Task{})
 }
+
+func TestUpdateFilter(t *testing.T) {
+	task := Task{ID: 1, Name: "a", State: 0, HospitalID: 2}
+	base := newTestDataBase()
+	db := base.Omit("HospitalID")
+	m := structToMapFunc(reflect.ValueOf(&task), db.updateFilter(func(_ reflect.StructField, _ string, value reflect.Value) bool {
+		return !isBlank(value)
+	}))
+	if !reflect.DeepEqual(m, map[string]interface{}{"id": 1, "name": "a"}) {
+		t.Errorf("non zero fields = %v", m)
+	}
+	if err := db.UpdateFields(&task, "name", "missing"); err != ErrArgs {
+		t.Errorf("err = %v, want ErrArgs", err)
+	}
+	if err := db.UpdateFields(&task, "name", "hospital_id"); err != ErrArgs {
+		t.Errorf("omitted field err = %v, want ErrArgs", err)
+	}
+	if len(base.omits) != 0 {
+		t.Error("Omit should not change the original DataBase")
+	}
+}
+
+type updateHookTask struct {
+	ID    int
+	Name  string
+	State int
+	fill  string `crud:"-"` //不为空的时候BeforeUpdate把Name设置成fill
+	calls int    `crud:"-"` //BeforeUpdate调用的次数
+}
+
+func (updateHookTask) DBName() string { return "task" }
+
+func (h *updateHookTask) BeforeUpdate() error {
+	h.calls++
+	if h.fill != "" {
+		h.Name = h.fill
+	}
+	return nil
+}
+
+func TestUpdateHookFillsFields(t *testing.T) {
+	db, state := newStubDataBase(t)
+	empty := updateHookTask{ID: 1}
+	if err := db.UpdateNonZero(&empty); err != ErrArgs {
+		t.Errorf("UpdateNonZero err = %v, want ErrArgs", err)
+	}
+	omitted := updateHookTask{ID: 1, Name: "a"}
+	if err := db.Omit("name", "state").Update(&omitted); err != ErrArgs {
+		t.Errorf("Update err = %v, want ErrArgs", err)
+	}
+	if empty.calls != 1 || omitted.calls != 1 || len(state.statements()) != 0 {
+		t.Errorf("BeforeUpdate called %d, %d times, statements %v", empty.calls, omitted.calls, state.statements())
+	}
+
+	filled := updateHookTask{ID: 1, fill: "operator"}
+	if err := db.UpdateNonZero(&filled); err != nil {
+		t.Fatal(err)
+	}
+	if statements := state.statements(); filled.calls != 1 || len(statements) != 1 || !strings.Contains(statements[0], "name") {
+		t.Errorf("BeforeUpdate called %d times, statements %v", filled.calls, statements)
+	}
+}
@@ -22,6 +22,19 @@ AfterUpdate
 AfterFind
 BeforeDelete
 AfterDelete
+BeforeSave
+AfterSave
+NewDataBaseWithConfig 通过Config(JSON/YAML/环境变量)创建连接
+Transaction 事务，Tx拥有和DataBase一样的CRUD/Table/Search方法，嵌套Transaction使用SAVEPOINT
+Paginate 分页查询，返回Items、Total、Page、Size、Pages
+Sum/Avg/Max/Min/CountDistinct/Exists/ExistsErr 聚合查询，使用Search上所有的条件和JOIN
+Pluck 查询一列放到[]int64、[]string、[]time.Time或者sql.Scanner的切片中
+RegisterScope/Scope/Scopes 复用查询条件，FormRead可以通过scope参数使用
+CreateMany/Creates 批量插入，INSERT ... VALUES (...),(...)
+Upsert/Save INSERT ... ON DUPLICATE KEY UPDATE
+UpdateMany 批量更新，UPDATE ... SET col = CASE id WHEN ? THEN ? ... END WHERE id IN (...)
+UpdateFields/UpdateNonZero/Omit 只更新部分字段
+Iterator/Each/Iterate 逐行读取查询结果，内存中只保留一行
 
 
 PLAN:
@@ -0,0 +1,148 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL的错误码
+// https://dev.mysql.com/doc/refman/8.0/en/server-error-reference.html
+const (
+	mysqlErrBadNull         = 1048
+	mysqlErrBadField        = 1054
+	mysqlErrDupEntry        = 1062
+	mysqlErrNoSuchTable     = 1146
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+	mysqlErrDataTooLong     = 1406
+	mysqlErrRowIsReferenced = 1451
+	mysqlErrNoReferencedRow = 1452
+)
+
+// MySQL错误码对应的错误，ErrInsertRepeat对应1062。
+var (
+	ErrForeignKey      = errors.New("外键约束错误")
+	ErrDeadlock        = errors.New("死锁")
+	ErrLockWaitTimeout = errors.New("锁等待超时")
+	ErrUnknownTable    = errors.New("表不存在")
+	ErrUnknownColumn   = errors.New("字段不存在")
+	ErrColumnNotNull   = errors.New("字段不能为NULL")
+	ErrDataTooLong     = errors.New("数据过长")
+)
+
+var (
+	reDupEntry   = regexp.MustCompile(`for key '([^']+)'`)
+	reForeignKey = regexp.MustCompile("\\(`[^`]+`\\.`([^`]+)`, CONSTRAINT `[^`]+` FOREIGN KEY \\(`([^`]+)`\\)")
+	reTable      = regexp.MustCompile(`Table '(?:[^'.]+\.)?([^']+)'`)
+	reColumn     = regexp.MustCompile(`(?:[Cc]olumn) '([^']+)'`)
+)
+
+// Error MySQL错误转换后的错误
+/*
+	errors.Is(err, crud.ErrInsertRepeat) 判断是否为唯一键冲突
+	errors.As(err, &mysqlErr) 可以拿到原始的*mysql.MySQLError
+*/
+type Error struct {
+	Err    error             //对应的错误，如ErrInsertRepeat、ErrForeignKey
+	Table  string            //表名，不一定有
+	Column string            //字段名，唯一键冲突时是索引名，不一定有
+	MySQL  *mysql.MySQLError //原始错误，在crud中检查出来的错误为nil
+}
+
+func (e *Error) Error() string {
+	var ctx []string
+	msg := e.Err.Error()
+	if e.Table != "" {
+		ctx = append(ctx, "table: "+e.Table)
+	}
+	if e.Column != "" {
+		ctx = append(ctx, "column: "+e.Column)
+	}
+	if len(ctx) > 0 {
+		msg = fmt.Sprintf("%s(%s)", msg, strings.Join(ctx, ", "))
+	}
+	if e.MySQL != nil {
+		msg += ": " + e.MySQL.Error()
+	}
+	return msg
+}
+
+// Is 用于errors.Is
+func (e *Error) Is(target error) bool {
+	return e.Err == target
+}
+
+// Unwrap 用于errors.As拿到*mysql.MySQLError
+func (e *Error) Unwrap() error {
+	if e.MySQL == nil {
+		return nil
+	}
+	return e.MySQL
+}
+
+// convertError 将MySQL的错误码转换成对应的Error，不认识的错误原样返回。
+func convertError(err error, tableName string) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Table == "" {
+			e.Table = tableName
+		}
+		return err
+	}
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return err
+	}
+	e = &Error{Table: tableName, MySQL: me}
+	switch me.Number {
+	case mysqlErrDupEntry:
+		e.Err = ErrInsertRepeat
+		if sm := reDupEntry.FindStringSubmatch(me.Message); sm != nil {
+			//MySQL8里面是 table.key
+			e.Column = sm[1][strings.LastIndex(sm[1], ".")+1:]
+		}
+	case mysqlErrNoReferencedRow, mysqlErrRowIsReferenced:
+		e.Err = ErrForeignKey
+		if sm := reForeignKey.FindStringSubmatch(me.Message); sm != nil {
+			//1451是删除被引用的行，错误信息中的表是引用它的子表。
+			if me.Number == mysqlErrNoReferencedRow || e.Table == "" {
+				e.Table = sm[1]
+			}
+			e.Column = sm[2]
+		}
+	case mysqlErrDeadlock:
+		e.Err = ErrDeadlock
+	case mysqlErrLockWaitTimeout:
+		e.Err = ErrLockWaitTimeout
+	case mysqlErrNoSuchTable:
+		e.Err = ErrUnknownTable
+		if sm := reTable.FindStringSubmatch(me.Message); sm != nil {
+			e.Table = sm[1]
+		}
+	case mysqlErrBadField:
+		e.Err = ErrUnknownColumn
+		if sm := reColumn.FindStringSubmatch(me.Message); sm != nil {
+			e.Column = sm[1]
+		}
+	case mysqlErrBadNull:
+		e.Err = ErrColumnNotNull
+		if sm := reColumn.FindStringSubmatch(me.Message); sm != nil {
+			e.Column = sm[1]
+		}
+	case mysqlErrDataTooLong:
+		e.Err = ErrDataTooLong
+		if sm := reColumn.FindStringSubmatch(me.Message); sm != nil {
+			e.Column = sm[1]
+		}
+	default:
+		return err
+	}
+	return e
+}
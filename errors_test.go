@@ -0,0 +1,34 @@
+package crud
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestConvertError(t *testing.T) {
+	me := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a' for key 'member.uniq_name'"}
+	err := convertError(me, "member")
+	if !errors.Is(err, ErrInsertRepeat) {
+		t.Fatalf("%v is not ErrInsertRepeat", err)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Table != "member" || e.Column != "uniq_name" {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	var raw *mysql.MySQLError
+	if !errors.As(err, &raw) || raw != me {
+		t.Fatal("want the original *mysql.MySQLError")
+	}
+
+	me = &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`demo`.`task`, CONSTRAINT `fk_hospital` FOREIGN KEY (`hospital_id`) REFERENCES `hospital` (`id`))"}
+	if !errors.As(convertError(me, ""), &e) || e.Err != ErrForeignKey || e.Table != "task" || e.Column != "hospital_id" {
+		t.Fatalf("unexpected error %#v", e)
+	}
+
+	other := errors.New("other")
+	if convertError(other, "task") != other {
+		t.Fatal("unknown errors must be returned as is")
+	}
+}
@@ -0,0 +1,250 @@
+package crud
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+//Iterator 逐行读取查询结果，内存中只保留当前这一行，用于导出等数据量很大的查询。
+/*
+	it := db.Table("task").Where("state = ?", 1).Iterator()
+	defer it.Close()
+	for it.Next() {
+		var task Task
+		if err := it.Scan(&task); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+
+	Next返回false的时候会自动Close，提前退出循环的时候需要调用Close释放连接。
+*/
+type Iterator struct {
+	rows       *sql.Rows
+	cols       []string
+	err        error
+	closed     bool
+	structType reflect.Type //fieldIdx对应的结构体类型
+	fieldIdx   []int        //每一列对应的结构体字段，没有的时候为-1
+}
+
+//Iterator 返回逐行读取的Iterator
+func (r *SQLRows) Iterator() *Iterator {
+	it := &Iterator{rows: r.rows, err: r.err}
+	if it.err != nil || it.rows == nil {
+		it.closed = true
+		return it
+	}
+	if it.cols, it.err = it.rows.Columns(); it.err != nil {
+		it.Close()
+	}
+	return it
+}
+
+//Next 读取下一行，没有数据或者出错的时候返回false并关闭。
+func (it *Iterator) Next() bool {
+	if it.closed {
+		return false
+	}
+	if !it.rows.Next() {
+		if err := it.rows.Err(); err != nil && it.err == nil {
+			it.err = err
+		}
+		it.Close()
+		return false
+	}
+	return true
+}
+
+//Columns 查询结果的字段名
+func (it *Iterator) Columns() []string {
+	return it.cols
+}
+
+//Scan 读取当前行
+/*
+	dest只有一个并且是*RowMap、*map[string]string时读取所有字段，
+	是结构体的指针时按照dbname标签或者字段名对应的数据库字段赋值，没有对应字段的列会被忽略，NULL为零值。
+	其他情况和sql.Rows.Scan一样，按顺序放到dest中。
+*/
+func (it *Iterator) Scan(dest ...interface{}) error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.closed {
+		return sql.ErrNoRows
+	}
+	if len(dest) == 1 {
+		switch d := dest[0].(type) {
+		case *RowMap:
+			return it.scanMap((*map[string]string)(d))
+		case *map[string]string:
+			return it.scanMap(d)
+		}
+		v := reflect.ValueOf(dest[0])
+		if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct && v.Elem().Type() != timeType && !v.Type().Implements(scannerType) {
+			return it.scanStruct(v.Elem())
+		}
+	}
+	if err := it.rows.Scan(dest...); err != nil {
+		it.err = err
+	}
+	return it.err
+}
+
+func (it *Iterator) scanMap(m *map[string]string) error {
+	raw := make([]sql.RawBytes, len(it.cols))
+	dest := make([]interface{}, len(it.cols))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	if it.err = it.rows.Scan(dest...); it.err != nil {
+		return it.err
+	}
+	row := make(map[string]string, len(it.cols))
+	for i, col := range it.cols {
+		row[col] = string(raw[i])
+	}
+	*m = row
+	return nil
+}
+
+//structFields 每一列对应的结构体字段，同一个Iterator中结构体类型不变的时候只计算一次。
+//crud:"-"、crud:"ignore"和不导出的字段会被忽略。
+func (it *Iterator) structFields(typ reflect.Type) []int {
+	if it.structType == typ {
+		return it.fieldIdx
+	}
+	fields := make(map[string]int, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("crud") == "-" || field.Tag.Get("crud") == "ignore" {
+			continue
+		}
+		dbName := field.Tag.Get("dbname")
+		if dbName == "" {
+			dbName = ToDBName(field.Name)
+		}
+		fields[dbName] = i
+	}
+	it.fieldIdx = make([]int, len(it.cols))
+	for i, col := range it.cols {
+		if idx, ok := fields[col]; ok {
+			it.fieldIdx[i] = idx
+		} else {
+			it.fieldIdx[i] = -1
+		}
+	}
+	it.structType = typ
+	return it.fieldIdx
+}
+
+func (it *Iterator) scanStruct(v reflect.Value) error {
+	fieldIdx := it.structFields(v.Type())
+	values := make([]interface{}, len(it.cols))
+	dest := make([]interface{}, len(it.cols))
+	direct := make([]bool, len(it.cols)) //字段实现了sql.Scanner，直接Scan到字段中
+	for i, idx := range fieldIdx {
+		dest[i] = &values[i]
+		if idx >= 0 {
+			field := v.Field(idx)
+			if field.CanAddr() && reflect.PtrTo(field.Type()).Implements(scannerType) {
+				dest[i] = field.Addr().Interface()
+				direct[i] = true
+			}
+		}
+	}
+	if it.err = it.rows.Scan(dest...); it.err != nil {
+		return it.err
+	}
+	for i, idx := range fieldIdx {
+		if idx < 0 || direct[i] || !canPluck(v.Field(idx).Type()) {
+			continue
+		}
+		field := v.Field(idx)
+		if values[i] == nil {
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+		if it.err = convertPluck(values[i], field); it.err != nil {
+			return it.err
+		}
+	}
+	return nil
+}
+
+//Err 读取过程中的错误
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+//Close 关闭底层的*sql.Rows，可以重复调用。
+func (it *Iterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	return it.rows.Close()
+}
+
+//Each 逐行调用fn，fn返回错误时停止并返回这个错误，结束后会关闭rows。
+func (r *SQLRows) Each(fn func(RowMap) error) error {
+	return r.Iterator().each(fn)
+}
+
+func (it *Iterator) each(fn func(RowMap) error) error {
+	defer it.Close()
+	for it.Next() {
+		var row RowMap
+		if err := it.Scan(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+//Iterator 执行查询并返回逐行读取的Iterator，Before查询出来的顺序是反的，不能使用。
+func (s *Search) Iterator() *Iterator {
+	if s.cursor != nil && s.cursor.before {
+		return (&SQLRows{err: ErrCursor}).Iterator()
+	}
+	return s.Rows().Iterator()
+}
+
+//Each 逐行调用fn，见SQLRows.Each。
+func (s *Search) Each(fn func(RowMap) error) error {
+	return s.Iterator().each(fn)
+}
+
+//Iterate 每读取一行放到dest中然后调用fn，dest为结构体的指针，会调用AfterFind钩子。
+//在事务中使用时结果集没读完之前连接不能执行别的语句，所以fn中不能再用这个事务查询。
+/*
+	var task Task
+	err := db.Table("task").Iterate(&task, func() error {
+		return w.Write(task)
+	})
+*/
+func (s *Search) Iterate(dest interface{}, fn func() error) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return ErrMustNeedAddr
+	}
+	it := s.Iterator()
+	defer it.Close()
+	for it.Next() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		if err := it.Scan(dest); err != nil {
+			return err
+		}
+		if err := callHook(v, AfterFind, s.table.DataBase); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
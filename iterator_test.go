@@ -0,0 +1,125 @@
+package crud
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIteratorError(t *testing.T) {
+	it := (&SQLRows{err: ErrNoConnection}).Iterator()
+	if it.Next() {
+		t.Fatal("Next should be false when the query failed")
+	}
+	if err := it.Err(); err != ErrNoConnection {
+		t.Errorf("err = %v, want ErrNoConnection", err)
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+
+	empty := (&SQLRows{}).Iterator()
+	var row RowMap
+	if empty.Next() || empty.Scan(&row) != sql.ErrNoRows {
+		t.Error("empty iterator should have no rows")
+	}
+
+	called := false
+	err := (&SQLRows{err: ErrNoConnection}).Each(func(RowMap) error {
+		called = true
+		return nil
+	})
+	if called || err != ErrNoConnection {
+		t.Errorf("Each called = %v, err = %v", called, err)
+	}
+}
+
+func TestSearchIterate(t *testing.T) {
+	search := newTestDataBase().Table("task").Where("state = ?", 1)
+	var task Task
+	if err := search.Iterate(task, func() error { return nil }); err != ErrMustNeedAddr {
+		t.Errorf("err = %v, want ErrMustNeedAddr", err)
+	}
+	err := search.Search.Eq("missing", 1).Iterate(&task, func() error { return nil })
+	if !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("err = %v, want ErrUnknownColumn", err)
+	}
+}
+
+type scanTask struct {
+	ID         int64
+	Name       string
+	HospitalID sql.NullInt64
+	Skipped    string `crud:"-"`
+	secret     string
+	CreatedAt  time.Time
+}
+
+func newScanDataBase(t *testing.T) *DataBase {
+	db, state := newStubDataBase(t)
+	state.columns = []string{"id", "name", "hospital_id", "skipped", "secret", "created_at"}
+	state.rows = [][]driver.Value{
+		{int64(1), []byte("a"), int64(3), []byte("x"), []byte("s"), []byte("2020-01-02 03:04:05")},
+		{int64(2), []byte("b"), nil, []byte("y"), []byte("t"), nil},
+	}
+	return db
+}
+
+func TestIteratorScanStruct(t *testing.T) {
+	it := newScanDataBase(t).Query("SELECT * FROM task").Iterator()
+	defer it.Close()
+	var tasks []scanTask
+	for it.Next() {
+		task := scanTask{Skipped: "keep", secret: "keep"}
+		if err := it.Scan(&task); err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []scanTask{
+		{ID: 1, Name: "a", HospitalID: sql.NullInt64{Int64: 3, Valid: true}, Skipped: "keep", secret: "keep", CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)},
+		{ID: 2, Name: "b", Skipped: "keep", secret: "keep"},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("tasks\n got: %+v\nwant: %+v", tasks, want)
+	}
+	if it.structType != reflect.TypeOf(scanTask{}) || !reflect.DeepEqual(it.fieldIdx, []int{0, 1, 2, -1, -1, 5}) {
+		t.Errorf("field cache = %v %v", it.structType, it.fieldIdx)
+	}
+}
+
+func TestIteratorScanMap(t *testing.T) {
+	db := newScanDataBase(t)
+	var rows []RowMap
+	err := db.Query("SELECT * FROM task").Each(func(row RowMap) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["name"] != "a" || rows[0]["hospital_id"] != "3" || rows[1]["hospital_id"] != "" || rows[1]["created_at"] != "" {
+		t.Errorf("rows = %v", rows)
+	}
+
+	it := db.Query("SELECT * FROM task").Iterator()
+	defer it.Close()
+	var m map[string]string
+	if !it.Next() || it.Scan(&m) != nil || m["id"] != "1" || m["secret"] != "s" {
+		t.Errorf("map = %v, err = %v", m, it.Err())
+	}
+	var id int64
+	var name string
+	if !it.Next() || it.Scan(&id, &name, new(interface{}), new(interface{}), new(interface{}), new(interface{})) != nil || id != 2 || name != "b" {
+		t.Errorf("id = %d, name = %s, err = %v", id, name, it.Err())
+	}
+	if it.Next() {
+		t.Error("Next should be false after the last row")
+	}
+}
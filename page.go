@@ -0,0 +1,288 @@
+package crud
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Page 分页的结果
+type Page struct {
+	Items interface{} `json:"items"` //Paginate是RowsMap，PaginateFinds是传入的结构体slice
+	Total int         `json:"total"` //总条数
+	Page  int         `json:"page"`  //当前页，从1开始
+	Size  int         `json:"size"`  //每页条数
+	Pages int         `json:"pages"` //总页数
+}
+
+func newPage(total, page, size int) *Page {
+	return &Page{
+		Total: total,
+		Page:  page,
+		Size:  size,
+		Pages: (total + size - 1) / size,
+	}
+}
+
+//Paginate 分页查询，page从1开始，返回的Items是RowsMap。
+//总数的查询会忽略ORDER BY、LIMIT、OFFSET，有GROUP BY的时候使用子查询计算。
+func (s *Search) Paginate(page, size int) (*Page, error) {
+	p, err := s.paginate(page, size)
+	if err != nil {
+		return nil, err
+	}
+	items := RowsMap{}
+	if p.Total > 0 {
+		rows := s.pageSearch(p).Rows()
+		items = rows.RowsMap()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	p.Items = items
+	return p, nil
+}
+
+//PaginateFinds 分页查询，数据放到v中，v必须是结构体slice的地址，Items就是v。
+func (s *Search) PaginateFinds(page, size int, v interface{}) (*Page, error) {
+	p, err := s.paginate(page, size)
+	if err != nil {
+		return nil, err
+	}
+	if p.Total > 0 {
+		if err := s.pageSearch(p).Finds(v); err != nil {
+			return nil, err
+		}
+	}
+	p.Items = v
+	return p, nil
+}
+
+func (s *Search) paginate(page, size int) (*Page, error) {
+	if size <= 0 {
+		return nil, ErrArgs
+	}
+	if page < 1 {
+		page = 1
+	}
+	total, err := s.CountErr()
+	if err != nil {
+		return nil, err
+	}
+	return newPage(total, page, size), nil
+}
+
+func (s *Search) pageSearch(p *Page) *Search {
+	return s.Limit(p.Size).Offset((p.Page - 1) * p.Size)
+}
+
+/*
+	游标分页
+
+	s := db.Table("task").OrderDesc("created_at").Limit(20).After(r.FormValue("cursor"))
+	rows := s.RowsMap()
+	next := s.NextCursor(rows)
+
+	使用ORDER BY的字段作为游标，没有按id排序的时候自动加上id作为最后一个排序字段，保证游标唯一。
+	WHERE (created_at, id) < (?, ?) ORDER BY created_at DESC, id DESC
+*/
+
+// cursorCon 游标条件
+type cursorCon struct {
+	values []interface{} //为空的时候是第一页，只排序不加条件
+	before bool
+}
+
+// EncodeCursor 将游标的值编码成base64字符串
+func EncodeCursor(values ...interface{}) string {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor 解码EncodeCursor生成的字符串
+func DecodeCursor(cursor string) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrCursor
+	}
+	var values []interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() //避免大的ID变成float64
+	if err := dec.Decode(&values); err != nil {
+		return nil, ErrCursor
+	}
+	return values, nil
+}
+
+//After 查询游标之后的数据，cursor为空的时候从头开始，排序和后面的页一样会加上id。
+func (s *Search) After(cursor string) *Search {
+	return s.setCursor(cursor, false)
+}
+
+//Before 查询游标之前的数据，cursor为空的时候不添加条件，查询的是最后一页。
+//SQL中的排序是反过来的，RowsMap、RowsMapInterface、DoubleSlice、Finds会把结果恢复成原来的顺序。
+func (s *Search) Before(cursor string) *Search {
+	return s.setCursor(cursor, true)
+}
+
+func (s *Search) setCursor(cursor string, before bool) *Search {
+	s = s.clone()
+	if cursor == "" {
+		s.cursor = &cursorCon{before: before}
+		return s
+	}
+	values, err := DecodeCursor(cursor)
+	if err != nil {
+		s.err = err
+		return s
+	}
+	s.cursor = &cursorCon{values: values, before: before}
+	return s
+}
+
+//NextCursor 结果中最后一行的游标，没有数据的时候返回""。
+func (s *Search) NextCursor(rows RowsMap) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	return s.cursorOf(rows[len(rows)-1])
+}
+
+//PrevCursor 结果中第一行的游标，用于Before，没有数据的时候返回""。
+func (s *Search) PrevCursor(rows RowsMap) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	return s.cursorOf(rows[0])
+}
+
+func (s *Search) cursorOf(row RowMap) string {
+	var values []interface{}
+	for _, oc := range s.keyOrders() {
+		if oc.column == "" {
+			return ""
+		}
+		values = append(values, row[oc.column])
+	}
+	return EncodeCursor(values...)
+}
+
+//keyOrders 游标使用的排序字段，没有主表的id的时候自动加上id，join的表的id不算。
+func (s *Search) keyOrders() []orderCon {
+	orders := append([]orderCon(nil), s.orders...)
+	desc := false
+	for _, oc := range orders {
+		if oc.column == "id" && (oc.table == "" || oc.table == s.tableName) {
+			return orders
+		}
+		desc = oc.desc
+	}
+	idField, _, _ := s.warpFieldSingel(s.tableName + ".id")
+	return append(orders, orderCon{field: idField, column: "id", desc: desc})
+}
+
+//sortOrders SQL中真正使用的排序，Before的时候方向相反。
+//有join的时候没有写表名的主表字段会加上主表的表名，避免和join的表中同名的字段冲突。
+func (s *Search) sortOrders() []orderCon {
+	orders := s.orders
+	if s.cursor != nil {
+		orders = s.keyOrders()
+		if s.cursor.before {
+			for i := range orders {
+				orders[i].desc = !orders[i].desc
+			}
+		}
+	}
+	if len(orders) == 0 || !s.joined() {
+		return orders
+	}
+	qualified := make([]orderCon, len(orders))
+	for i, oc := range orders {
+		if oc.table == "" && oc.column != "" && s.table.tableColumns[s.tableName].HaveColumn(oc.column) {
+			oc.field, _, _ = s.warpFieldSingel(s.tableName + "." + oc.column)
+		}
+		qualified[i] = oc
+	}
+	return qualified
+}
+
+//cursorCondition 方向都一样的时候是 (a, b) > (?, ?)
+//方向不一样的时候是 (a > ?) OR (a = ? AND b < ?)
+func (s *Search) cursorCondition() (WhereCon, bool) {
+	if s.cursor == nil || len(s.cursor.values) == 0 || s.checkCursor() != nil {
+		return WhereCon{}, false
+	}
+	var (
+		orders  = s.sortOrders()
+		values  = s.cursor.values
+		fields  []string
+		sameDir = true
+	)
+	for _, oc := range orders {
+		fields = append(fields, oc.field)
+		sameDir = sameDir && oc.desc == orders[0].desc
+	}
+	if sameDir {
+		op := ">"
+		if orders[0].desc {
+			op = "<"
+		}
+		return WhereCon{
+			Query: fmt.Sprintf("(%s) %s (%s)", strings.Join(fields, ", "), op, placeholder(len(fields))),
+			Args:  values,
+		}, true
+	}
+	var (
+		ors  []string
+		args []interface{}
+	)
+	for i, oc := range orders {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, fields[j]+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if oc.desc {
+			op = "<"
+		}
+		ands = append(ands, fields[i]+" "+op+" ?")
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return WhereCon{Query: "(" + strings.Join(ors, " OR ") + ")", Args: args}, true
+}
+
+func (s *Search) checkCursor() error {
+	if s.cursor == nil {
+		return nil
+	}
+	orders := s.keyOrders()
+	if len(s.cursor.values) > 0 && len(orders) != len(s.cursor.values) {
+		return ErrCursor
+	}
+	for _, oc := range orders {
+		if oc.column == "" {
+			return ErrCursor
+		}
+	}
+	return nil
+}
+
+//restoreOrder Before查询出来的结果是反的，恢复成原来的顺序。
+func (s *Search) restoreOrder(slice interface{}) {
+	if s.cursor == nil || !s.cursor.before {
+		return
+	}
+	rv := reflect.ValueOf(slice)
+	swap := reflect.Swapper(slice)
+	for i, j := 0, rv.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
@@ -0,0 +1,215 @@
+package crud
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Pluck相关的错误
+var (
+	ErrPluckDest = errors.New("Pluck的dest必须是切片的指针，元素为整数、浮点数、string、bool、[]byte、time.Time或者sql.Scanner")
+	ErrNullValue = errors.New("查询结果中有NULL")
+)
+
+//NullMode Pluck遇到NULL时的处理方式，元素是sql.Scanner的时候NULL交给Scan处理。
+type NullMode int
+
+// NullMode的取值
+const (
+	NullZero  NullMode = iota //NULL作为零值，默认
+	NullSkip                  //跳过NULL
+	NullError                 //返回ErrNullValue
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+//Pluck 只查询field一列并放到dest中，dest为切片的指针，原来的内容会被清空。
+/*
+	var ids []int64
+	err := db.Table("task").Where("state = ?", 1).Pluck("id", &ids)
+
+	var names []sql.NullString
+	err := db.Table("task").Pluck("name", &names)
+
+	var times []time.Time
+	err := db.Table("task").Pluck("created_at", &times, crud.NullSkip)
+*/
+func (s *Search) Pluck(field string, dest interface{}, mode ...NullMode) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice || !canPluck(rv.Elem().Type().Elem()) {
+		return ErrPluckDest
+	}
+	if err := s.check(); err != nil {
+		return err
+	}
+	query, args, err := s.pluckQuery(field)
+	if err != nil {
+		return err
+	}
+	null := NullZero
+	if len(mode) > 0 {
+		null = mode[0]
+	}
+	slice := rv.Elem()
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+	if err := s.table.Query(query, args...).pluck(slice, null); err != nil {
+		return err
+	}
+	s.restoreOrder(slice.Interface())
+	return nil
+}
+
+//pluckQuery 只查询field一列的语句，field是其他表的字段时自动join这张表。
+func (s *Search) pluckQuery(field string) (string, []interface{}, error) {
+	column, err := s.column(field)
+	if err != nil {
+		return "", nil, err
+	}
+	sub := s.joinField(field)
+	sub.selects = column
+	query, args := sub.Parse()
+	return query, args, nil
+}
+
+//pluck 将第一列逐行转换后追加到slice中
+func (r *SQLRows) pluck(slice reflect.Value, null NullMode) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.rows == nil {
+		return nil
+	}
+	defer r.close()
+	typ := slice.Type().Elem()
+	scanner := reflect.PtrTo(typ).Implements(scannerType)
+	for r.rows.Next() {
+		if scanner {
+			v := reflect.New(typ)
+			if r.err = r.rows.Scan(v.Interface()); r.err != nil {
+				return r.err
+			}
+			slice.Set(reflect.Append(slice, v.Elem()))
+			continue
+		}
+		var raw interface{}
+		if r.err = r.rows.Scan(&raw); r.err != nil {
+			return r.err
+		}
+		if raw == nil {
+			switch null {
+			case NullSkip:
+				continue
+			case NullError:
+				r.err = ErrNullValue
+				return r.err
+			}
+			slice.Set(reflect.Append(slice, reflect.Zero(typ)))
+			continue
+		}
+		v := reflect.New(typ).Elem()
+		if r.err = convertPluck(raw, v); r.err != nil {
+			return r.err
+		}
+		slice.Set(reflect.Append(slice, v))
+	}
+	r.close()
+	return r.err
+}
+
+func canPluck(typ reflect.Type) bool {
+	if typ == timeType || reflect.PtrTo(typ).Implements(scannerType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	case reflect.Slice:
+		return typ.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+//convertPluck 将驱动返回的值转换后放到v中
+//没有parseTime的时候DATETIME返回的是[]byte，按TimeFormat在本地时区解析。
+func convertPluck(raw interface{}, v reflect.Value) error {
+	if t, ok := raw.(time.Time); ok {
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(t))
+			return nil
+		}
+		raw = t.Format(TimeFormat)
+	}
+	var str string
+	switch x := raw.(type) {
+	case []byte:
+		if v.Kind() == reflect.Slice {
+			v.SetBytes(append([]byte(nil), x...))
+			return nil
+		}
+		str = string(x)
+	case string:
+		str = x
+	case int64:
+		str = strconv.FormatInt(x, 10)
+	case float64:
+		str = strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		str = strconv.FormatBool(x)
+	default:
+		str = fmt.Sprint(x)
+	}
+	if v.Type() == timeType {
+		layout := TimeFormat
+		if len(str) == len("2006-01-02") {
+			layout = "2006-01-02"
+		}
+		t, err := time.ParseInLocation(layout, str, time.Local)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(str)
+	case reflect.Slice:
+		v.SetBytes([]byte(str))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(str, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return ErrPluckDest
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package crud
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertPluck(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	cases := []struct {
+		raw  interface{}
+		dest interface{}
+		want interface{}
+	}{
+		{[]byte("9007199254740993"), int64(0), int64(9007199254740993)},
+		{int64(12), "", "12"},
+		{[]byte("abc"), "", "abc"},
+		{[]byte("2020-01-02 03:04:05"), time.Time{}, created},
+		{created, "", "2020-01-02 03:04:05"},
+		{[]byte("1.5"), float64(0), 1.5},
+		{int64(1), false, true},
+	}
+	for _, c := range cases {
+		v := reflect.New(reflect.TypeOf(c.dest)).Elem()
+		if err := convertPluck(c.raw, v); err != nil {
+			t.Errorf("convertPluck(%v) error: %v", c.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Interface(), c.want) {
+			t.Errorf("convertPluck(%v) = %v, want %v", c.raw, v.Interface(), c.want)
+		}
+	}
+	if err := convertPluck([]byte("300"), reflect.New(reflect.TypeOf(int8(0))).Elem()); err == nil {
+		t.Error("convertPluck should fail on overflow")
+	}
+}
+
+func TestPluckDest(t *testing.T) {
+	search := newTestDataBase().Table("task")
+	var ids []int64
+	var m map[string]int
+	for _, dest := range []interface{}{ids, &m, &[]struct{}{}} {
+		if err := search.Pluck("id", dest); err != ErrPluckDest {
+			t.Errorf("Pluck(%T) = %v, want ErrPluckDest", dest, err)
+		}
+	}
+}
+
+func TestPluckQuery(t *testing.T) {
+	search := newTestDataBase().Table("task").Where("state = ?", 1).OrderDesc("id")
+	query, args, err := search.pluckQuery("hospital.name")
+	if err != nil || query != "SELECT hospital.`name` FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE state = ? AND is_deleted = ? ORDER BY task.`id` DESC" || !reflect.DeepEqual(args, []interface{}{1, 0}) {
+		t.Errorf("pluck query %s %v %v", query, args, err)
+	}
+	query, _, _ = search.Joins("hospital").pluckQuery("hospital.name")
+	if query != "SELECT hospital.`name` FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE state = ? AND is_deleted = ? ORDER BY task.`id` DESC" {
+		t.Errorf("pluck query should not join twice: %s", query)
+	}
+	if _, _, err := search.pluckQuery("hospital.nmae"); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("err = %v, want ErrUnknownColumn", err)
+	}
+}
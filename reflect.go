@@ -26,6 +26,8 @@ const (
 	AfterUpdate  = "AfterUpdate"
 	BeforeDelete = "BeforeDelete"
 	AfterDelete  = "AfterDelete"
+	BeforeSave   = "BeforeSave"
+	AfterSave    = "AfterSave"
 
 	CreatedAt = "created_at"
 	UpdatedAt = "updated_at"
@@ -150,6 +152,34 @@ func getStructID(v reflect.Value) int64 {
 	return 0
 }
 
+// callHook 调用结构体上的BeforeCreate、AfterFind等钩子，没有就不调用。
+// 钩子可以是 func() 、func() error 或者 func(db *DataBase) error，
+// 带参数的钩子会拿到当前的DataBase，在事务中调用时这个DataBase也在事务中。
+func callHook(v reflect.Value, name string, db *DataBase) error {
+	hook := v.MethodByName(name)
+	if !hook.IsValid() {
+		return nil
+	}
+	var in []reflect.Value
+	switch hook.Type().NumIn() {
+	case 0:
+	case 1:
+		if hook.Type().In(0) != reflect.TypeOf(db) {
+			return ErrHookSignature
+		}
+		in = append(in, reflect.ValueOf(db))
+	default:
+		return ErrHookSignature
+	}
+	vals := hook.Call(in)
+	if len(vals) > 0 {
+		if err, ok := vals[len(vals)-1].Interface().(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // 检查反射的值是否为默认值，如果为默认值则默认为空值。
 func isBlank(value reflect.Value) bool {
 	switch value.Kind() {
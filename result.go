@@ -21,6 +21,24 @@ type SQLRows struct {
 	err  error
 }
 
+// Err 返回查询或者读取结果时发生的错误
+// RowsMap等方法在出错的时候返回空值，调用后可以通过Err区分是没有数据还是数据库出错了。
+// Int、String、Scan没有数据的时候Err返回sql.ErrNoRows。
+func (r *SQLRows) Err() error {
+	return r.err
+}
+
+// close 读取完毕后关闭rows，并记录遍历过程中的错误。
+func (r *SQLRows) close() {
+	if r.rows == nil {
+		return
+	}
+	if err := r.rows.Err(); err != nil && r.err == nil {
+		r.err = err
+	}
+	r.rows.Close()
+}
+
 //为了兼容以前的代码这里设置四个转发的函数，以后肯定会慢慢移除掉的。
 
 //RawMapInterface RowMapInterface
@@ -108,11 +126,13 @@ func (r *SQLRows) RowMapInterface() RowMapInterface {
 */
 func (r *SQLRows) RowsMapInterface() RowsMapInterface {
 	rs := []map[string]interface{}{}
-	if r.err != nil {
+	if r.err != nil || r.rows == nil {
 		return rs
 	}
+	defer r.close()
 	cols, err := r.rows.Columns()
 	if err != nil {
+		r.err = err
 		return rs
 	}
 
@@ -144,7 +164,10 @@ func (r *SQLRows) RowsMapInterface() RowsMapInterface {
 			}
 
 		}
-		r.rows.Scan(containers...)
+		if err := r.rows.Scan(containers...); err != nil {
+			r.err = err
+			return []map[string]interface{}{}
+		}
 		for i := 0; i < len(cols); i++ {
 			//rowMap[cols[i]] = string(*containers[i].(*[]byte))
 			for i := 0; i < cap(containers); i++ {
@@ -194,7 +217,12 @@ func (r *SQLRows) RowsMap() RowsMap {
 	if r.rows == nil {
 		return rs
 	}
-	cols, _ := r.rows.Columns()
+	defer r.close()
+	cols, err := r.rows.Columns()
+	if err != nil {
+		r.err = err
+		return rs
+	}
 
 	for r.rows.Next() {
 		//type RawBytes []byte
@@ -203,7 +231,10 @@ func (r *SQLRows) RowsMap() RowsMap {
 		for i := 0; i < cap(containers); i++ {
 			containers = append(containers, &[]byte{})
 		}
-		r.rows.Scan(containers...)
+		if err := r.rows.Scan(containers...); err != nil {
+			r.err = err
+			return make([]map[string]string, 0)
+		}
 		for i := 0; i < len(cols); i++ {
 			rowMap[cols[i]] = string(*containers[i].(*[]byte))
 			//rowMap[cols[i]] = *(*string)(unsafe.Pointer(containers[i].(*[]byte)))
@@ -226,11 +257,13 @@ func (r *SQLRows) RowMap() RowMap {
 func (r *SQLRows) DoubleSlice() (map[string]int, [][]string) {
 	cols := make([]string, 0)
 	datas := make([][]string, 0)
-	if r.err != nil {
+	if r.err != nil || r.rows == nil {
 		return map[string]int{}, datas
 	}
+	defer r.close()
 	cols, err := r.rows.Columns()
 	if err != nil {
+		r.err = err
 		return map[string]int{}, datas
 	}
 	rawResult := make([][]byte, len(cols))
@@ -241,7 +274,8 @@ func (r *SQLRows) DoubleSlice() (map[string]int, [][]string) {
 	for r.rows.Next() {
 		err := r.rows.Scan(dest...)
 		if err != nil {
-			return map[string]int{}, datas
+			r.err = err
+			return map[string]int{}, make([][]string, 0)
 		}
 		result := make([]string, len(cols))
 		for i, raw := range rawResult {
@@ -261,27 +295,30 @@ func (r *SQLRows) DoubleSlice() (map[string]int, [][]string) {
 }
 
 // Int SCAN 一个int类型，只能在只有一列中使用。
+// 出错或者没有数据时返回0，通过Err获取错误。
 func (r *SQLRows) Int() int {
-	if r.err != nil {
+	count := 0
+	if err := r.Scan(&count); err != nil {
 		return 0
 	}
-	count := 0
-	r.Scan(&count)
 	return count
 }
 
+// String SCAN 一个string类型，出错或者没有数据时返回""，通过Err获取错误。
 func (r *SQLRows) String() string {
-	if r.err != nil {
+	str := ""
+	if err := r.Scan(&str); err != nil {
 		return ""
 	}
-	str := ""
-	r.Scan(&str)
 	return str
 }
 
 // Find 将结果查找后放到结构体中
 func (r *SQLRows) Find(v interface{}) error {
 	m := r.RowsMapInterface()
+	if r.err != nil {
+		return r.err
+	}
 	rv := reflect.ValueOf(v).Elem()
 	//如果查询是数组的话
 	if rv.Kind() == reflect.Slice {
@@ -353,16 +390,25 @@ func (r *SQLRows) setValue(v reflect.Value, i interface{}) {
 }
 
 // Scan 当只需要一列中的一个数据是可以使用Scan,比如 select count(*) from tablename
+// 没有数据的时候返回sql.ErrNoRows。
 func (r *SQLRows) Scan(v interface{}) error {
 	if r.err != nil {
 		return r.err
 	}
-	if r.rows.Next() {
-		err := r.rows.Scan(v)
-		r.rows.Close()
-		return err
+	if r.rows == nil {
+		return sql.ErrNoRows
 	}
-	return nil
+	defer r.close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			r.err = err
+			return err
+		}
+		r.err = sql.ErrNoRows
+		return r.err
+	}
+	r.err = r.rows.Scan(v)
+	return r.err
 }
 
 func queryRows(rows *sql.Rows) RowsMap {
@@ -398,31 +444,47 @@ func scanRows(rows *sql.Rows) RowMap {
 }
 
 // SQLResult 是一个封装了sql.Result 的结构体
-//type SQLResult struct {
-//	ret sql.Result
-//	err error
-//}
-
-//// ID 获取插入的ID
-//func (r *SQLResult) ID() (int64, error) {
-//	if r.err != nil {
-//		return 0, r.err
-//	}
-//	id, err := r.ret.LastInsertId()
-//	if err != nil {
-//		return 0, err
-//	}
-//	return id, nil
-//}
-
-//// Effected 获取影响行数
-//func (r *SQLResult) Effected() (int64, error) {
-//	if r.err != nil {
-//		return 0, r.err
-//	}
-//	affected, err := r.ret.RowsAffected()
-//	if err != nil {
-//		return 0, err
-//	}
-//	return affected, nil
-//}
+// Exec出错的时候不会返回nil，而是在ID、Effected等方法中返回错误。
+type SQLResult struct {
+	ret sql.Result
+	err error
+}
+
+// Err 执行时发生的错误
+func (r *SQLResult) Err() error {
+	return r.err
+}
+
+// ID 获取插入的ID
+func (r *SQLResult) ID() (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	id, err := r.ret.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Effected 获取影响行数
+func (r *SQLResult) Effected() (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	affected, err := r.ret.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// LastInsertId 实现sql.Result，同ID。
+func (r *SQLResult) LastInsertId() (int64, error) {
+	return r.ID()
+}
+
+// RowsAffected 实现sql.Result，同Effected。
+func (r *SQLResult) RowsAffected() (int64, error) {
+	return r.Effected()
+}
@@ -0,0 +1,79 @@
+package crud
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestSQLRowsError(t *testing.T) {
+	errQuery := errors.New("query")
+	rows := &SQLRows{err: errQuery}
+	if data := rows.RowsMap(); data == nil || len(data) != 0 {
+		t.Errorf("RowsMap = %#v, want empty", data)
+	}
+	if rows.Err() != errQuery {
+		t.Errorf("Err = %v, want the query error", rows.Err())
+	}
+	if data := (&SQLRows{err: errQuery}).RowsMapInterface(); len(data) != 0 {
+		t.Errorf("RowsMapInterface = %#v, want empty", data)
+	}
+	if _, data := (&SQLRows{err: errQuery}).DoubleSlice(); len(data) != 0 {
+		t.Errorf("DoubleSlice = %#v, want empty", data)
+	}
+	var v int
+	if err := (&SQLRows{err: errQuery}).Scan(&v); err != errQuery {
+		t.Errorf("Scan = %v, want the query error", err)
+	}
+}
+
+func TestSQLRowsNoRows(t *testing.T) {
+	db, state := newStubDataBase(t)
+	state.columns = []string{"id"}
+	var id int
+	rows := db.Query("SELECT id FROM task")
+	if err := rows.Scan(&id); err != sql.ErrNoRows {
+		t.Errorf("Scan = %v, want sql.ErrNoRows", err)
+	}
+	if rows.Err() != sql.ErrNoRows {
+		t.Errorf("Err = %v, want sql.ErrNoRows", rows.Err())
+	}
+
+	state.rows = [][]driver.Value{{int64(3)}}
+	if n := db.Query("SELECT id FROM task").Int(); n != 3 {
+		t.Errorf("Int = %d, want 3", n)
+	}
+
+	state.fail = "FROM task"
+	rows = db.Query("SELECT id FROM task")
+	if data := rows.RowsMap(); len(data) != 0 || !errors.Is(rows.Err(), errStub) {
+		t.Errorf("RowsMap = %v, err = %v, want empty and errStub", data, rows.Err())
+	}
+}
+
+func TestSQLResultError(t *testing.T) {
+	errExec := errors.New("exec")
+	ret := &SQLResult{err: errExec}
+	if id, err := ret.ID(); id != 0 || err != errExec {
+		t.Errorf("ID = %d, %v", id, err)
+	}
+	if n, err := ret.Effected(); n != 0 || err != errExec {
+		t.Errorf("Effected = %d, %v", n, err)
+	}
+	if _, err := ret.LastInsertId(); err != errExec {
+		t.Errorf("LastInsertId err = %v", err)
+	}
+	if _, err := ret.RowsAffected(); err != errExec {
+		t.Errorf("RowsAffected err = %v", err)
+	}
+
+	db, state := newStubDataBase(t)
+	state.fail = "UPDATE"
+	if err := db.Exec("UPDATE task SET state = 1").Err(); !errors.Is(err, errStub) {
+		t.Errorf("Exec err = %v, want errStub", err)
+	}
+	if _, err := newTestDataBase().Exec("UPDATE task SET state = 1").ID(); err != ErrNoConnection {
+		t.Errorf("ID without connection = %v, want ErrNoConnection", err)
+	}
+}
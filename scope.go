@@ -0,0 +1,87 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownScope 没有注册过的scope
+var ErrUnknownScope = errors.New("scope不存在")
+
+//scopesMu 保护DataBase.scopes的延迟初始化
+var scopesMu sync.Mutex
+
+//ScopeFunc 可以复用的查询条件，如租户、状态、时间范围
+type ScopeFunc func(*Search) *Search
+
+//scopeRegistry 按表保存注册的scope，DataBase复制的时候共享同一个。
+type scopeRegistry struct {
+	mu     sync.RWMutex
+	scopes map[string]map[string]ScopeFunc
+}
+
+func newScopeRegistry() *scopeRegistry {
+	return &scopeRegistry{scopes: make(map[string]map[string]ScopeFunc)}
+}
+
+func (r *scopeRegistry) get(tableName, name string) (ScopeFunc, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn, ok := r.scopes[tableName][name]
+	return fn, ok
+}
+
+//RegisterScope 给表注册一个命名的scope，同名的会被覆盖，之后可以通过Scope(name)使用。
+/*
+	db.RegisterScope("task", "active", func(s *crud.Search) *crud.Search {
+		return s.Eq("state", 1)
+	})
+	db.Table("task").Scope("active").RowsMap()
+*/
+func (db *DataBase) RegisterScope(tableName, name string, fn ScopeFunc) {
+	r := db.scopeRegistry()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.scopes[tableName] == nil {
+		r.scopes[tableName] = make(map[string]ScopeFunc)
+	}
+	r.scopes[tableName][name] = fn
+}
+
+//scopeRegistry 不是NewDataBaseWithConfig创建的DataBase在第一次注册的时候才创建，
+//这之前复制出来的DataBase看不到注册的scope。
+func (db *DataBase) scopeRegistry() *scopeRegistry {
+	scopesMu.Lock()
+	defer scopesMu.Unlock()
+	if db.scopes == nil {
+		db.scopes = newScopeRegistry()
+	}
+	return db.scopes
+}
+
+//Scopes 依次使用fns
+func (s *Search) Scopes(fns ...ScopeFunc) *Search {
+	s = s.clone()
+	for _, fn := range fns {
+		s = fn(s)
+	}
+	return s
+}
+
+//Scope 依次使用注册过的scope，不存在的时候执行返回ErrUnknownScope。
+func (s *Search) Scope(names ...string) *Search {
+	s = s.clone()
+	for _, name := range names {
+		fn, ok := s.table.scopeRegistry().get(s.tableName, name)
+		if !ok {
+			s.err = fmt.Errorf("%w: %s.%s", ErrUnknownScope, s.tableName, name)
+			return s
+		}
+		s = fn(s)
+	}
+	return s
+}
@@ -1,9 +1,13 @@
 package crud
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //JoinCon join条件
@@ -15,6 +19,15 @@ type JoinCon struct {
 //JoinCons join条件slice
 type JoinCons []JoinCon
 
+//clause LEFT JOIN ... ON ...
+func (jc JoinCons) clause() string {
+	var joins string
+	for _, joincon := range jc {
+		joins += fmt.Sprintf(" LEFT JOIN %s ON %s", joincon.TableName, joincon.Condition)
+	}
+	return joins
+}
+
 //HaveTable join条件中是否已经添加了这张表的join
 func (jc JoinCons) HaveTable(tableName string) bool {
 	for _, v := range jc {
@@ -29,52 +42,225 @@ func (jc JoinCons) HaveTable(tableName string) bool {
 type WhereCon struct {
 	Query string
 	Args  []interface{}
+	Or    bool       //和前一个条件之间用OR连接，默认为AND
+	Not   bool       //NOT (Query)
+	Group []WhereCon //括号中的子条件，不为空的时候忽略Query和Args
+
+	sub *Search //子查询，生成的时候是 Query (SELECT ...)
+}
+
+//WhereCons where条件slice
+type WhereCons []WhereCon
+
+//HaveOr 最外层是否有OR连接的条件
+func (wcs WhereCons) HaveOr() bool {
+	for _, wc := range wcs {
+		if wc.Or {
+			return true
+		}
+	}
+	return false
+}
+
+//Build 生成条件语句和参数
+//a = ? AND (b = ? OR c = ?) AND NOT (d = ?)
+func (wcs WhereCons) Build() (string, []interface{}) {
+	var (
+		sb   strings.Builder
+		args = []interface{}{}
+	)
+	for i, wc := range wcs {
+		expr, exprArgs := wc.Query, wc.Args
+		if wc.sub != nil {
+			subQuery, subArgs := wc.sub.Parse()
+			expr = wc.Query + " (" + subQuery + ")"
+			exprArgs = append(append([]interface{}{}, wc.Args...), subArgs...)
+		}
+		if len(wc.Group) > 0 {
+			expr, exprArgs = WhereCons(wc.Group).Build()
+			expr = "(" + expr + ")"
+		}
+		if wc.Not {
+			if len(wc.Group) > 0 {
+				expr = "NOT " + expr
+			} else {
+				expr = "NOT (" + expr + ")"
+			}
+		}
+		if i > 0 {
+			if wc.Or {
+				sb.WriteString(" OR ")
+			} else {
+				sb.WriteString(" AND ")
+			}
+		}
+		sb.WriteString(expr)
+		args = append(args, exprArgs...)
+	}
+	return sb.String(), args
+}
+
+// withCon WITH name AS (search)
+type withCon struct {
+	name   string
+	search *Search
+}
+
+// unionCon UNION [ALL] (search)
+type unionCon struct {
+	search *Search
+	all    bool
+}
+
+// orderCon ORDER BY中的一个排序字段
+type orderCon struct {
+	field  string //已经warp过的字段或者表达式
+	table  string //字段前面写的表名，没有写的时候为空
+	column string //字段名，用于游标分页时从结果中取值，表达式为空
+	desc   bool
+}
+
+func (oc orderCon) String() string {
+	if oc.desc {
+		return oc.field + " DESC"
+	}
+	return oc.field + " ASC"
 }
 
 //Search 搜索结构体
 type Search struct {
-	table           *Table
-	fields          []string
-	tableName       string
-	joinConditions  JoinCons
-	whereConditions []WhereCon
-	group           string
-	with            string
-	having          string
-	limit           interface{}
-	offset          interface{}
-
-	query string
-	args  []interface{}
-	raw   bool
-}
-
-//Clone 克隆一个当前结构体
+	table            *Table
+	fields           []string
+	selects          string //不为空的时候代替fields，原样放在SELECT后面，用于聚合函数
+	tableName        string
+	joinConditions   JoinCons
+	joinTables       []string //条件、排序中用到的其他表，Parse的时候自动join，见joinField
+	whereConditions  WhereCons
+	group            string
+	withs            []withCon
+	from             *Search //FROM (SELECT ...) AS tableName
+	havingConditions WhereCons
+	unions           []unionCon
+	orders           []orderCon
+	cursor           *cursorCon //游标分页，见After、Before
+	lock             string     //FOR UPDATE、FOR SHARE
+	lockOption       string     //SKIP LOCKED、NOWAIT
+	limit            interface{}
+	offset           interface{}
+	err              error //链式调用中产生的错误，在执行的时候返回
+	force            bool  //UpdateAll、DeleteAll没有条件的时候也执行
+	inplace          bool  //Where(func(s *Search))中的s直接修改自己，不克隆
+}
+
+//Clone 克隆一个当前结构体，克隆后的修改不会影响原来的结构体。
 func (s *Search) Clone() *Search {
 	clone := *s
+	clone.inplace = false
+	clone.fields = append([]string(nil), s.fields...)
+	clone.joinConditions = append(JoinCons(nil), s.joinConditions...)
+	clone.joinTables = append([]string(nil), s.joinTables...)
+	clone.whereConditions = append(WhereCons(nil), s.whereConditions...)
+	clone.withs = append([]withCon(nil), s.withs...)
+	clone.havingConditions = append(WhereCons(nil), s.havingConditions...)
+	clone.unions = append([]unionCon(nil), s.unions...)
+	clone.orders = append([]orderCon(nil), s.orders...)
 	return &clone
 }
 
+//clone 链式调用的每一步都返回一个新的Search，所以同一个Search可以反复作为基础查询使用。
+func (s *Search) clone() *Search {
+	if s.inplace {
+		return s
+	}
+	return s.Clone()
+}
+
+// WithContext 返回一个使用ctx执行语句的Search
+func (s *Search) WithContext(ctx context.Context) *Search {
+	table := &Table{DataBase: s.table.DataBase.WithContext(ctx), tableName: s.table.tableName}
+	clone := s.Clone()
+	clone.table = table
+	table.Search = clone
+	return clone
+}
+
 //Fields 需要查询的字段
 func (s *Search) Fields(args ...string) *Search {
+	s = s.clone()
 	s.fields = append(s.fields, args...)
 	return s
 }
 
-//Where where语法
-func (s *Search) Where(query string, values ...interface{}) *Search {
-	s.whereConditions = append(s.whereConditions, WhereCon{Query: query, Args: values})
+//Where where语法，多个Where之间是AND
+//query可以是字符串，也可以是func(s *Search)或者func(s *Search) *Search，在函数中添加的条件会被括号括起来：
+//	s.Where(func(s *Search) {
+//		s.Where("status = ?", 1).Or("status = ?", 2)
+//	}).Where("owner_id = ?", 3)
+//	(status = ? OR status = ?) AND owner_id = ?
+func (s *Search) Where(query interface{}, values ...interface{}) *Search {
+	return s.addCondition(query, values, false, false)
+}
+
+//Or 和前一个条件之间用OR连接，query和Where一样。
+func (s *Search) Or(query interface{}, values ...interface{}) *Search {
+	return s.addCondition(query, values, true, false)
+}
+
+//Not AND NOT (query)，query和Where一样。
+func (s *Search) Not(query interface{}, values ...interface{}) *Search {
+	return s.addCondition(query, values, false, true)
+}
+
+//OrNot OR NOT (query)，query和Where一样。
+func (s *Search) OrNot(query interface{}, values ...interface{}) *Search {
+	return s.addCondition(query, values, true, true)
+}
+
+func (s *Search) addCondition(query interface{}, values []interface{}, or, not bool) *Search {
+	s = s.clone()
+	wc := WhereCon{Or: or, Not: not}
+	switch q := query.(type) {
+	case string:
+		wc.Query, wc.Args = q, values
+	case func(*Search), func(*Search) *Search:
+		sub := &Search{table: s.table, tableName: s.tableName, inplace: true}
+		if fn, ok := q.(func(*Search)); ok {
+			fn(sub)
+		} else {
+			sub = q.(func(*Search) *Search)(sub)
+		}
+		if sub.err != nil {
+			s.err = sub.err
+			return s
+		}
+		s.joinTables = append(s.joinTables, sub.joinTables...)
+		if len(sub.whereConditions) == 0 {
+			return s
+		}
+		wc.Group = sub.whereConditions
+	default:
+		s.err = ErrArgs
+		return s
+	}
+	s.whereConditions = append(s.whereConditions, wc)
 	return s
 }
 
+//Err 链式调用中产生的错误
+func (s *Search) Err() error {
+	return s.err
+}
+
 //WhereID id = ?
 func (s *Search) WhereID(id interface{}) *Search {
+	s = s.clone()
 	s.whereConditions = append(s.whereConditions, WhereCon{Query: s.tableName + ".id = ?", Args: []interface{}{id}})
 	return s
 }
 
 //In in语法
 func (s *Search) In(field string, args ...interface{}) *Search {
+	s = s.clone()
 	//In没有参数的话SQL就会报错
 	if len(args) == 0 {
 		return s
@@ -83,99 +269,509 @@ func (s *Search) In(field string, args ...interface{}) *Search {
 	return s
 }
 
+//NotIn NOT IN (?,?)
+func (s *Search) NotIn(field string, args ...interface{}) *Search {
+	//NOT IN没有参数的时候是所有数据，所以不添加条件。
+	if len(args) == 0 {
+		return s.clone()
+	}
+	return s.fieldCondition(field, fmt.Sprintf("NOT IN (%s)", placeholder(len(args))), args...)
+}
+
+//Eq field = ?
+func (s *Search) Eq(field string, value interface{}) *Search {
+	return s.fieldCondition(field, "= ?", value)
+}
+
+//Ne field <> ?
+func (s *Search) Ne(field string, value interface{}) *Search {
+	return s.fieldCondition(field, "<> ?", value)
+}
+
+//Gt field > ?
+func (s *Search) Gt(field string, value interface{}) *Search {
+	return s.fieldCondition(field, "> ?", value)
+}
+
+//Gte field >= ?
+func (s *Search) Gte(field string, value interface{}) *Search {
+	return s.fieldCondition(field, ">= ?", value)
+}
+
+//Lt field < ?
+func (s *Search) Lt(field string, value interface{}) *Search {
+	return s.fieldCondition(field, "< ?", value)
+}
+
+//Lte field <= ?
+func (s *Search) Lte(field string, value interface{}) *Search {
+	return s.fieldCondition(field, "<= ?", value)
+}
+
+//Between field BETWEEN ? AND ?
+func (s *Search) Between(field string, start, end interface{}) *Search {
+	return s.fieldCondition(field, "BETWEEN ? AND ?", start, end)
+}
+
+//IsNull field IS NULL
+func (s *Search) IsNull(field string) *Search {
+	return s.fieldCondition(field, "IS NULL")
+}
+
+//NotNull field IS NOT NULL
+func (s *Search) NotNull(field string) *Search {
+	return s.fieldCondition(field, "IS NOT NULL")
+}
+
+//Like field LIKE ?，pattern原样使用，需要自己处理%和_。
+func (s *Search) Like(field string, pattern string) *Search {
+	return s.fieldCondition(field, "LIKE ?", pattern)
+}
+
+//StartsWith field LIKE 'value%'，value中的%和_会被转义。
+func (s *Search) StartsWith(field string, value string) *Search {
+	return s.fieldCondition(field, "LIKE ?", escapeLike(value)+"%")
+}
+
+//EndsWith field LIKE '%value'，value中的%和_会被转义。
+func (s *Search) EndsWith(field string, value string) *Search {
+	return s.fieldCondition(field, "LIKE ?", "%"+escapeLike(value))
+}
+
+//Contains field LIKE '%value%'，value中的%和_会被转义。
+func (s *Search) Contains(field string, value string) *Search {
+	return s.fieldCondition(field, "LIKE ?", "%"+escapeLike(value)+"%")
+}
+
+//fieldCondition 添加 `field` op 这样的条件，字段不在表中的时候记录ErrUnknownColumn。
+//field是其他表的字段时自动join这张表。
+func (s *Search) fieldCondition(field string, op string, args ...interface{}) *Search {
+	column, err := s.column(field)
+	if err != nil {
+		s = s.clone()
+		s.err = err
+		return s
+	}
+	s = s.joinField(field)
+	s.whereConditions = append(s.whereConditions, WhereCon{Query: column + " " + op, Args: args})
+	return s
+}
+
+//column 给字段加上`，如果知道这张表的所有列，那么检查字段是否存在。
+func (s *Search) column(field string) (string, error) {
+	warp, tableName, fieldName := s.warpFieldSingel(field)
+	if cols, ok := s.table.tableColumns[tableName]; ok && !cols.HaveColumn(fieldName) {
+		return "", &Error{Err: ErrUnknownColumn, Table: tableName, Column: fieldName}
+	}
+	return warp, nil
+}
+
+//InSub field IN (SELECT ...)，字段不在表中的时候记录ErrUnknownColumn。
+func (s *Search) InSub(field string, sub *Search) *Search {
+	return s.fieldSubCondition(field, "IN", sub)
+}
+
+//NotInSub field NOT IN (SELECT ...)，字段不在表中的时候记录ErrUnknownColumn。
+func (s *Search) NotInSub(field string, sub *Search) *Search {
+	return s.fieldSubCondition(field, "NOT IN", sub)
+}
+
+func (s *Search) fieldSubCondition(field string, op string, sub *Search) *Search {
+	column, err := s.column(field)
+	if err != nil {
+		s = s.clone()
+		s.err = err
+		return s
+	}
+	return s.joinField(field).subCondition(column+" "+op, sub)
+}
+
+//WhereExists EXISTS (SELECT ...)
+func (s *Search) WhereExists(sub *Search) *Search {
+	return s.subCondition("EXISTS", sub)
+}
+
+//WhereNotExists NOT EXISTS (SELECT ...)
+func (s *Search) WhereNotExists(sub *Search) *Search {
+	return s.subCondition("NOT EXISTS", sub)
+}
+
+func (s *Search) subCondition(query string, sub *Search) *Search {
+	s = s.clone()
+	if sub.err != nil {
+		s.err = sub.err
+		return s
+	}
+	s.whereConditions = append(s.whereConditions, WhereCon{Query: query, sub: sub})
+	return s
+}
+
+//From FROM (SELECT ...) AS alias，之后的字段和条件使用alias作为表名。
+func (s *Search) From(sub *Search, alias string) *Search {
+	s = s.clone()
+	if sub.err != nil {
+		s.err = sub.err
+	}
+	s.from = sub
+	s.tableName = alias
+	return s
+}
+
 //Joins join语法，自动连表。
 func (s *Search) Joins(tablename string, condition ...string) *Search {
+	s = s.clone()
 	if len(condition) == 1 {
 		s.joinConditions = append(s.joinConditions, JoinCon{TableName: tablename, Condition: condition[0]})
-	} else {
-		if s.table.tableColumns[tablename].HaveColumn(s.tableName + "id") {
-			s.joinConditions = append(s.joinConditions, JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", tablename, s.tableName+"id", s.tableName)})
-		} else if s.table.tableColumns[tablename].HaveColumn(s.tableName + "_id") {
-			s.joinConditions = append(s.joinConditions, JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", tablename, s.tableName+"_id", s.tableName)})
-		} else if s.table.tableColumns[s.tableName].HaveColumn(tablename + "id") {
-			s.joinConditions = append(s.joinConditions, JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", s.tableName, tablename+"id", tablename)})
-		} else if s.table.tableColumns[s.tableName].HaveColumn(tablename + "_id") {
-			s.joinConditions = append(s.joinConditions, JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", s.tableName, tablename+"_id", tablename)})
-		}
+	} else if jc, ok := s.autoJoin(tablename); ok {
+		s.joinConditions = append(s.joinConditions, jc)
 	}
 	return s
 }
 
+//autoJoin 根据两张表的xxx_id字段找出join条件
+func (s *Search) autoJoin(tablename string) (JoinCon, bool) {
+	if s.table.tableColumns[tablename].HaveColumn(s.tableName + "id") {
+		return JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", tablename, s.tableName+"id", s.tableName)}, true
+	} else if s.table.tableColumns[tablename].HaveColumn(s.tableName + "_id") {
+		return JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", tablename, s.tableName+"_id", s.tableName)}, true
+	} else if s.table.tableColumns[s.tableName].HaveColumn(tablename + "id") {
+		return JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", s.tableName, tablename+"id", tablename)}, true
+	} else if s.table.tableColumns[s.tableName].HaveColumn(tablename + "_id") {
+		return JoinCon{TableName: tablename, Condition: fmt.Sprintf("%s.%s = %s.id", s.tableName, tablename+"_id", tablename)}, true
+	}
+	return JoinCon{}, false
+}
+
 //TableName tableName
 func (s *Search) TableName(name string) *Search {
+	s = s.clone()
 	s.tableName = name
 	return s
 }
 
+//Order ORDER BY，可以是 "created_at DESC" 或者 "state, created_at DESC" 这样多个字段。
+//字段会被加上`，表达式原样保留。
+func (s *Search) Order(query string) *Search {
+	s = s.clone()
+	for _, part := range splitTopLevel(query) {
+		sp := strings.Fields(part)
+		if len(sp) == 0 {
+			continue
+		}
+		oc := orderCon{field: part}
+		if last := strings.ToUpper(sp[len(sp)-1]); len(sp) > 1 && (last == "ASC" || last == "DESC") {
+			oc.desc = last == "DESC"
+			oc.field = strings.Join(sp[:len(sp)-1], " ")
+		}
+		if isIdentifier(oc.field) {
+			oc.table = qualifier(oc.field)
+			oc.field, _, oc.column = s.warpFieldSingel(oc.field)
+		}
+		s.addJoinTable(oc.table)
+		s.orders = append(s.orders, oc)
+	}
+	return s
+}
+
+//OrderAsc ORDER BY field ASC，field是其他表的字段时自动join这张表，Order也一样。
+func (s *Search) OrderAsc(fields ...string) *Search {
+	s = s.clone()
+	for _, field := range fields {
+		oc := s.orderField(field, false)
+		s.addJoinTable(oc.table)
+		s.orders = append(s.orders, oc)
+	}
+	return s
+}
+
+//OrderDesc ORDER BY field DESC
+func (s *Search) OrderDesc(fields ...string) *Search {
+	s = s.clone()
+	for _, field := range fields {
+		oc := s.orderField(field, true)
+		s.addJoinTable(oc.table)
+		s.orders = append(s.orders, oc)
+	}
+	return s
+}
+
+func (s *Search) orderField(field string, desc bool) orderCon {
+	warp, _, column := s.warpField(field)
+	if !isIdentifier(field) {
+		return orderCon{field: warp, desc: desc}
+	}
+	return orderCon{field: warp, table: qualifier(field), column: column, desc: desc}
+}
+
+//qualifier table.field中的表名，没有的时候返回""
+func qualifier(field string) string {
+	if i := strings.Index(field, "."); i >= 0 {
+		return field[:i]
+	}
+	return ""
+}
+
 //Limit LIMIT ?
 func (s *Search) Limit(limit interface{}) *Search {
+	s = s.clone()
 	s.limit = limit
 	return s
 }
 
 //Offset OFFSET ?
 func (s *Search) Offset(offset interface{}) *Search {
+	s = s.clone()
 	s.offset = offset
 	return s
 }
 
-//Group GROUP BY
+//Group GROUP BY，多个字段用逗号分开，字段会被加上`。
 func (s *Search) Group(query string) *Search {
-	s.group = query
+	s = s.clone()
+	var groups []string
+	for _, part := range splitTopLevel(query) {
+		if isIdentifier(part) {
+			part, _, _ = s.warpFieldSingel(part)
+		}
+		groups = append(groups, part)
+	}
+	s.group = strings.Join(groups, ", ")
+	return s
+}
+
+//Having HAVING，用法和Where一样，多个Having之间是AND。
+func (s *Search) Having(query string, values ...interface{}) *Search {
+	s = s.clone()
+	s.havingConditions = append(s.havingConditions, WhereCon{Query: query, Args: values})
+	return s
+}
+
+//With WITH name AS (SELECT ...)，MySQL8的公共表表达式。
+//在当前Search中可以把name当作表来使用，比如Joins(name, "...")或者另外一个TableName(name)的Search。
+func (s *Search) With(name string, search *Search) *Search {
+	s = s.clone()
+	s.withs = append(s.withs, withCon{name: name, search: search})
+	return s
+}
+
+//Union UNION (SELECT ...)，当前Search的Order、Limit、Offset作用于合并后的结果。
+func (s *Search) Union(other *Search) *Search {
+	return s.union(other, false)
+}
+
+//UnionAll UNION ALL (SELECT ...)，不去重。
+func (s *Search) UnionAll(other *Search) *Search {
+	return s.union(other, true)
+}
+
+func (s *Search) union(other *Search, all bool) *Search {
+	s = s.clone()
+	if other.err != nil {
+		s.err = other.err
+	}
+	s.unions = append(s.unions, unionCon{search: other, all: all})
+	return s
+}
+
+//ForUpdate SELECT ... FOR UPDATE，只能在事务中使用。
+func (s *Search) ForUpdate() *Search {
+	s = s.clone()
+	s.lock = "FOR UPDATE"
 	return s
 }
 
+//ForShare SELECT ... FOR SHARE，只能在事务中使用，需要MySQL8。
+func (s *Search) ForShare() *Search {
+	s = s.clone()
+	s.lock = "FOR SHARE"
+	return s
+}
+
+//SkipLocked 跳过被锁住的行，和ForUpdate、ForShare一起使用，需要MySQL8。
+//	tx.Table("job").Where("state = ?", 0).Limit(10).ForUpdate().SkipLocked().RowsMap()
+func (s *Search) SkipLocked() *Search {
+	s = s.clone()
+	s.lockOption = "SKIP LOCKED"
+	return s
+}
+
+//NoWait 行被锁住的时候立即返回错误，和ForUpdate、ForShare一起使用，需要MySQL8。
+func (s *Search) NoWait() *Search {
+	s = s.clone()
+	s.lockOption = "NOWAIT"
+	return s
+}
+
+func (s *Search) lockClause() string {
+	if s.lock == "" {
+		return ""
+	}
+	if s.lockOption == "" {
+		return " " + s.lock
+	}
+	return " " + s.lock + " " + s.lockOption
+}
+
 //Parse 将各个条件整合成可以查询的SQL语句和参数
+//Parse不会修改Search，同一个Search多次Parse的结果是一样的。
 func (s *Search) Parse() (string, []interface{}) {
-	if s.raw == true {
-		return s.query, s.args
-	}
 	var (
-		fields       string
-		joins        string
-		paddingwhere string
-		wheres       []string
-		limit        string
-		offset       string
+		with   string
+		group  string
+		having string
+		limit  string
+		offset string
+		args   = []interface{}{}
 	)
-	if s.table.tableColumns[s.tableName].HaveColumn(IsDeleted) {
-		s.Where("is_deleted = ?", 0)
-	}
-	s.query = ""
-	s.args = []interface{}{}
-	if len(s.fields) == 0 {
-		fields = "*"
-	} else {
-		for i := 0; i < len(s.fields); i++ {
-			var tableName string
-			s.fields[i], tableName, _ = s.warpField(s.fields[i])
-			if tableName != s.tableName {
-				if !s.joinConditions.HaveTable(tableName) {
-					s.Joins(tableName)
-				}
-			}
+	if len(s.withs) > 0 {
+		var ctes []string
+		for _, wc := range s.withs {
+			query, withArgs := wc.search.Parse()
+			ctes = append(ctes, fmt.Sprintf("`%s` AS (%s)", wc.name, query))
+			args = append(args, withArgs...)
 		}
-		fields = strings.Join(s.fields, ",")
+		with = "WITH " + strings.Join(ctes, ", ") + " "
 	}
-	for _, joincon := range s.joinConditions {
-		joins += fmt.Sprintf(" LEFT JOIN %s ON %s", joincon.TableName, joincon.Condition)
+	from := s.tableName
+	if s.from != nil {
+		query, fromArgs := s.from.Parse()
+		from = fmt.Sprintf("(%s) AS `%s`", query, s.tableName)
+		args = append(args, fromArgs...)
+	}
+	fields, joinConditions := s.fieldsClause()
+	where, whereArgs := s.whereClause()
+	args = append(args, whereArgs...)
+	if s.group != "" {
+		group = " GROUP BY " + s.group
 	}
-	for _, wherecon := range s.whereConditions {
-		paddingwhere = " WHERE "
-		wheres = append(wheres, wherecon.Query)
-		s.args = append(s.args, wherecon.Args...)
+	if len(s.havingConditions) > 0 {
+		havings, havingArgs := s.havingConditions.Build()
+		having = " HAVING " + havings
+		args = append(args, havingArgs...)
 	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s%s%s%s", fields, from, joinConditions.clause(), where, group, having)
+	if len(s.unions) > 0 {
+		query = "(" + query + ")"
+		for _, uc := range s.unions {
+			unionQuery, unionArgs := uc.search.Parse()
+			if uc.all {
+				query += " UNION ALL (" + unionQuery + ")"
+			} else {
+				query += " UNION (" + unionQuery + ")"
+			}
+			args = append(args, unionArgs...)
+		}
+	}
+	orders := s.orderClause()
 	if s.limit != nil {
 		limit = " LIMIT ?"
-		s.args = append(s.args, s.limit)
+		args = append(args, s.limit)
 	}
 	if s.offset != nil {
 		offset = " OFFSET ?"
-		s.args = append(s.args, s.offset)
+		args = append(args, s.offset)
+	}
+	return with + query + orders + limit + offset + s.lockClause(), args
+}
+
+// fieldsClause 给字段加上`，字段中有其他表的时候自动join这张表。
+func (s *Search) fieldsClause() (string, JoinCons) {
+	joinConditions := s.joins()
+	fields := make([]string, 0, len(s.fields))
+	for _, field := range s.fields {
+		warp, tableName, _ := s.warpField(field)
+		fields = append(fields, warp)
+		joinConditions = s.appendAutoJoin(joinConditions, tableName)
+	}
+	//selects代替fields的时候也保留fields中的join，条件中可能用到了这些表
+	if s.selects != "" {
+		return s.selects, joinConditions
+	}
+	if len(fields) == 0 {
+		return "*", joinConditions
+	}
+	return strings.Join(fields, ","), joinConditions
+}
+
+// joined 查询中是否有join，UNION的ORDER BY是对整个结果排序，不算。
+func (s *Search) joined() bool {
+	if len(s.unions) > 0 {
+		return false
+	}
+	_, joinConditions := s.fieldsClause()
+	return len(joinConditions) > 0
+}
+
+// appendAutoJoin tableName不是主表并且还没有join的时候自动join，不会修改原来的joinConditions。
+func (s *Search) appendAutoJoin(joinConditions JoinCons, tableName string) JoinCons {
+	if tableName == s.tableName || joinConditions.HaveTable(tableName) {
+		return joinConditions
+	}
+	if jc, ok := s.autoJoin(tableName); ok {
+		return append(joinConditions[:len(joinConditions):len(joinConditions)], jc)
+	}
+	return joinConditions
+}
+
+// joinField 记录field所在的表，Parse的时候自动join，用于条件、排序和selects中的字段。
+// 在Parse的时候才join，所以之后用Joins指定的join条件优先。
+func (s *Search) joinField(field string) *Search {
+	_, tableName, _ := s.warpFieldSingel(field)
+	s = s.clone()
+	s.addJoinTable(tableName)
+	return s
+}
+
+// addJoinTable tableName不是主表的时候记录到joinTables中，会修改s，调用前需要clone。
+func (s *Search) addJoinTable(tableName string) {
+	if tableName != "" && tableName != s.tableName {
+		s.joinTables = append(s.joinTables, tableName)
+	}
+}
+
+// joins Joins指定的join加上joinTables中需要自动join的表
+func (s *Search) joins() JoinCons {
+	joinConditions := s.joinConditions
+	for _, tableName := range s.joinTables {
+		joinConditions = s.appendAutoJoin(joinConditions, tableName)
+	}
+	return joinConditions
+}
+
+// whereClause WHERE ...，有is_deleted的表会自动加上is_deleted = 0。
+func (s *Search) whereClause() (string, []interface{}) {
+	wcs := s.whereConditions
+	if s.table.tableColumns[s.tableName].HaveColumn(IsDeleted) {
+		//有OR的时候要先把原来的条件括起来，不然 a OR b AND is_deleted = 0 就不对了。
+		if wcs.HaveOr() {
+			wcs = WhereCons{{Group: wcs}}
+		}
+		wcs = append(wcs[:len(wcs):len(wcs)], WhereCon{Query: "is_deleted = ?", Args: []interface{}{0}})
+	}
+	if wc, ok := s.cursorCondition(); ok {
+		if wcs.HaveOr() {
+			wcs = WhereCons{{Group: wcs}}
+		}
+		wcs = append(wcs[:len(wcs):len(wcs)], wc)
+	}
+	if len(wcs) == 0 {
+		return "", []interface{}{}
 	}
-	s.query = fmt.Sprintf("SELECT %s FROM %s%s%s%s%s%s", fields, s.tableName, joins, paddingwhere, strings.Join(wheres, " AND "), limit, offset)
-	s.raw = true
-	return s.query, s.args
+	where, args := wcs.Build()
+	return " WHERE " + where, args
+}
+
+// orderClause ORDER BY ...
+func (s *Search) orderClause() string {
+	orders := s.sortOrders()
+	if len(orders) == 0 {
+		return ""
+	}
+	var obs []string
+	for _, oc := range orders {
+		obs = append(obs, oc.String())
+	}
+	return " ORDER BY " + strings.Join(obs, ", ")
 }
 
 //DISTINCT XX
@@ -239,69 +835,219 @@ func (s *Search) RawsMapInterface() RowsMapInterface {
 	return s.RowsMapInterface()
 }
 
+// Rows 执行查询并返回SQLRows，需要区分错误的时候使用。
+//	rows := s.Rows()
+//	data := rows.RowsMap()
+//	if err := rows.Err(); err != nil {}
+func (s *Search) Rows() *SQLRows {
+	if err := s.check(); err != nil {
+		return &SQLRows{err: err}
+	}
+	query, args := s.Parse()
+	return s.table.Query(query, args...)
+}
+
 //RowMap RowMap
 func (s *Search) RowMap() RowMap {
-	query, args := s.Parse()
-	return s.table.Query(query, args...).RowMap()
+	return s.Rows().RowMap()
 }
 
 //RowsMap RowsMap
 func (s *Search) RowsMap() RowsMap {
-	query, args := s.Parse()
-	return s.table.Query(query, args...).RowsMap()
+	rows := s.Rows().RowsMap()
+	s.restoreOrder(rows)
+	return rows
 }
 
 //RowsMapInterface RowsMapInterface
 func (s *Search) RowsMapInterface() RowsMapInterface {
-	query, args := s.Parse()
-	return s.table.Query(query, args...).RowsMapInterface()
+	rows := s.Rows().RowsMapInterface()
+	s.restoreOrder(rows)
+	return rows
 }
 
 //DoubleSlice DoubleSlice
 func (s *Search) DoubleSlice() (map[string]int, [][]string) {
-	query, args := s.Parse()
-	return s.table.Query(query, args...).DoubleSlice()
+	cols, rows := s.Rows().DoubleSlice()
+	s.restoreOrder(rows)
+	return cols, rows
+}
+
+//check 执行前检查链式调用中的错误
+func (s *Search) check() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.lockOption != "" && s.lock == "" {
+		return ErrArgs
+	}
+	if s.lock != "" && s.table.tx == nil {
+		return ErrNeedTransaction
+	}
+	return s.checkCursor()
 }
 
 //Int 如果指定字段，则返回指定字段的int值，否则返回第一个字段作为int值返回。
 func (s *Search) Int(args ...string) int {
-	row := s.RowMap()
-	if len(args) == 0 {
-		for _, v := range row {
-			i, _ := strconv.Atoi(v)
-			return i
-		}
-	} else {
-		i, _ := strconv.Atoi(row[args[0]])
-		return i
-	}
-	return 0
+	i, _ := strconv.Atoi(s.String(args...))
+	return i
 }
 
 //String like int
 func (s *Search) String(args ...string) string {
-	row := s.RowMap()
+	cols, rows := s.DoubleSlice()
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return ""
+	}
 	if len(args) == 0 {
-		for _, v := range row {
-			return v
-		}
-	} else {
-		return row[args[0]]
+		return rows[0][0]
+	}
+	if i, ok := cols[args[0]]; ok {
+		return rows[0][i]
 	}
 	return ""
 }
 
 //Finds 将查询的结构放入到结构体当中
-func (s *Search) Finds(v interface{}) {
+func (s *Search) Finds(v interface{}) error {
+	if err := s.check(); err != nil {
+		return err
+	}
 	query, args := s.Parse()
-	s.table.FindAll(v, append([]interface{}{query}, args...)...)
+	if err := s.table.FindAll(v, append([]interface{}{query}, args...)...); err != nil {
+		return err
+	}
+	if rv := reflect.Indirect(reflect.ValueOf(v)); rv.Kind() == reflect.Slice {
+		s.restoreOrder(rv.Interface())
+	}
+	return nil
 }
 
-//Count 计算这次查询结果的个数
+//Count 计算这次查询结果的个数，出错时返回0，需要错误的时候使用CountErr。
 func (s *Search) Count() int {
-	var count int
-	s.fields = []string{"COUNT(*)"}
-	query, args := s.Parse()
-	s.table.Query(query, args...).Find(&count)
+	count, _ := s.CountErr()
 	return count
 }
+
+//CountErr 计算这次查询结果的个数
+func (s *Search) CountErr() (int, error) {
+	var count int
+	if s.err != nil {
+		return 0, s.err
+	}
+	query, args := s.countQuery()
+	err := s.table.Query(query, args...).Scan(&count)
+	return count, err
+}
+
+//countQuery 查询总数的语句，见aggregateQuery。
+func (s *Search) countQuery() (string, []interface{}) {
+	return s.aggregateQuery("COUNT(*)", "COUNT(*)", "")
+}
+
+func (s *Search) isDistinct() bool {
+	for _, field := range s.fields {
+		if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(field)), "DISTINCT") {
+			return true
+		}
+	}
+	return false
+}
+
+//批量更新、删除
+
+//Force 允许UpdateAll、DeleteAll在没有条件的时候更新或删除整张表
+func (s *Search) Force() *Search {
+	s = s.clone()
+	s.force = true
+	return s
+}
+
+//UpdateAll 根据条件更新所有符合的数据，返回影响的行数
+//	db.Table("task").Where("state = ?", 1).In("hospital_id", 1, 2).UpdateAll(map[string]interface{}{"state": 2})
+//有updated_at字段的时候会自动更新updated_at，没有条件的时候返回ErrNoCondition，除非调用了Force。
+//m的key必须是主表的字段，不是字段名或者不在表中的时候返回ErrArgs或者ErrUnknownColumn。
+func (s *Search) UpdateAll(m map[string]interface{}) (int64, error) {
+	if err := s.checkBatch(); err != nil {
+		return 0, err
+	}
+	if len(m) == 0 {
+		return 0, ErrArgs
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if !isIdentifier(k) || strings.Contains(k, ".") {
+			return 0, ErrArgs
+		}
+		if _, err := s.column(k); err != nil {
+			return 0, err
+		}
+		keys = append(keys, k)
+	}
+	//排序保证每次生成的语句和参数的顺序一样
+	sort.Strings(keys)
+	sets := []string{}
+	args := []interface{}{}
+	for _, k := range keys {
+		sets = append(sets, fmt.Sprintf("`%s`.`%s` = ?", s.tableName, k))
+		args = append(args, m[k])
+	}
+	if _, ok := m[UpdatedAt]; !ok && s.table.tableColumns[s.tableName].HaveColumn(UpdatedAt) {
+		sets = append(sets, fmt.Sprintf("`%s`.`%s` = ?", s.tableName, UpdatedAt))
+		args = append(args, time.Now().Format(TimeFormat))
+	}
+	return s.execBatch(fmt.Sprintf("UPDATE `%s`%s SET %s", s.tableName, s.joins().clause(), strings.Join(sets, ", ")), args)
+}
+
+//DeleteAll 根据条件删除所有符合的数据，返回影响的行数
+//有is_deleted字段的表是软删除，没有条件的时候返回ErrNoCondition，除非调用了Force。
+func (s *Search) DeleteAll() (int64, error) {
+	if err := s.checkBatch(); err != nil {
+		return 0, err
+	}
+	cols := s.table.tableColumns[s.tableName]
+	if cols.HaveColumn(IsDeleted) {
+		sets := []string{fmt.Sprintf("`%s`.`%s` = 1", s.tableName, IsDeleted)}
+		args := []interface{}{}
+		if cols.HaveColumn(DeletedAt) {
+			sets = append(sets, fmt.Sprintf("`%s`.`%s` = ?", s.tableName, DeletedAt))
+			args = append(args, time.Now().Format(TimeFormat))
+		}
+		return s.execBatch(fmt.Sprintf("UPDATE `%s`%s SET %s", s.tableName, s.joins().clause(), strings.Join(sets, ", ")), args)
+	}
+	if len(s.joins()) > 0 {
+		return s.execBatch(fmt.Sprintf("DELETE `%s` FROM `%s`%s", s.tableName, s.tableName, s.joins().clause()), nil)
+	}
+	return s.execBatch(fmt.Sprintf("DELETE FROM `%s`", s.tableName), nil)
+}
+
+func (s *Search) checkBatch() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.from != nil || len(s.unions) > 0 {
+		return ErrArgs
+	}
+	if len(s.whereConditions) == 0 && !s.force {
+		return ErrNoCondition
+	}
+	//多表的UPDATE、DELETE不能用ORDER BY和LIMIT，忽略的话会更新或删除更多的数据
+	if len(s.joins()) > 0 && (len(s.orders) > 0 || s.limit != nil) {
+		return ErrArgs
+	}
+	return nil
+}
+
+//execBatch 在query后面加上WHERE、ORDER BY和LIMIT，多表的时候checkBatch保证没有ORDER BY和LIMIT。
+func (s *Search) execBatch(query string, args []interface{}) (int64, error) {
+	where, whereArgs := s.whereClause()
+	query += where
+	args = append(args, whereArgs...)
+	query += s.orderClause()
+	if s.limit != nil {
+		query += " LIMIT ?"
+		args = append(args, s.limit)
+	}
+	affected, err := s.table.Exec(query, args...).RowsAffected()
+	return affected, convertError(err, s.tableName)
+}
@@ -0,0 +1,341 @@
+package crud
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newTestDataBase 不连接数据库，只用于测试SQL语句的生成。
+func newTestDataBase() *DataBase {
+	return &DataBase{
+		scopes: newScopeRegistry(),
+		tableColumns: map[string]Columns{
+			"task": {
+				"id":          Column{Name: "id"},
+				"name":        Column{Name: "name"},
+				"state":       Column{Name: "state"},
+				"hospital_id": Column{Name: "hospital_id"},
+				"created_at":  Column{Name: "created_at"},
+				"is_deleted":  Column{Name: "is_deleted"},
+			},
+			"hospital": {
+				"id":   Column{Name: "id"},
+				"name": Column{Name: "name"},
+			},
+		},
+	}
+}
+
+func assertParse(t *testing.T, s *Search, query string, args ...interface{}) {
+	t.Helper()
+	gotQuery, gotArgs := s.Parse()
+	if gotQuery != query {
+		t.Errorf("query\n got: %s\nwant: %s", gotQuery, query)
+	}
+	if len(args) == 0 {
+		args = []interface{}{}
+	}
+	if !reflect.DeepEqual(gotArgs, args) {
+		t.Errorf("args\n got: %#v\nwant: %#v", gotArgs, args)
+	}
+}
+
+func TestSearchOrder(t *testing.T) {
+	table := newTestDataBase().Table("hospital")
+	assertParse(t, table.Order("name DESC, FIELD(id, 3, 1)").OrderAsc("hospital.id").Limit(10).Search,
+		"SELECT * FROM hospital ORDER BY `name` DESC, FIELD(id, 3, 1) ASC, hospital.`id` ASC LIMIT ?", 10)
+
+	joined := newTestDataBase().Table("task").Fields("task.id", "hospital.name AS hospital_name").OrderDesc("hospital_name", "id").Search
+	assertParse(t, joined, "SELECT task.`id`,hospital.`name` AS hospital_name FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE is_deleted = ? ORDER BY `hospital_name` DESC, task.`id` DESC", 0)
+}
+
+func TestSearchGroupHavingWith(t *testing.T) {
+	db := newTestDataBase()
+	counts := db.Table("task").Fields("hospital_id", "COUNT(*) AS total").Group("hospital_id").Having("COUNT(*) > ?", 5).Search
+	report := db.Table("hospital").With("task_count", counts).Joins("task_count", "task_count.hospital_id = hospital.id").Where("hospital.id > ?", 1).Search
+	assertParse(t, report,
+		"WITH `task_count` AS (SELECT `hospital_id`,COUNT(*) AS total FROM task WHERE is_deleted = ? GROUP BY `hospital_id` HAVING COUNT(*) > ?) SELECT * FROM hospital LEFT JOIN task_count ON task_count.hospital_id = hospital.id WHERE hospital.id > ?",
+		0, 5, 1)
+}
+
+func TestSearchOrNotGroup(t *testing.T) {
+	table := newTestDataBase().Table("task")
+	s := table.Where(func(s *Search) {
+		s.Where("state = ?", 1).Or("state = ?", 2)
+	}).Where("hospital_id = ?", 3).Not("name = ?", "x").Search
+	assertParse(t, s, "SELECT * FROM task WHERE (state = ? OR state = ?) AND hospital_id = ? AND NOT (name = ?) AND is_deleted = ?", 1, 2, 3, "x", 0)
+
+	s = table.Where("state = ?", 1).Or("state = ?", 2).Search
+	assertParse(t, s, "SELECT * FROM task WHERE (state = ? OR state = ?) AND is_deleted = ?", 1, 2, 0)
+}
+
+func TestSearchConditionHelpers(t *testing.T) {
+	table := newTestDataBase().Table("task")
+	s := table.Between("created_at", "2018-01-01", "2018-02-01").Contains("task.name", "50%_off").IsNull("hospital.name").NotIn("state", 1, 2).Gte("task.id", 10).Search
+	assertParse(t, s, "SELECT * FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE `created_at` BETWEEN ? AND ? AND task.`name` LIKE ? AND hospital.`name` IS NULL AND `state` NOT IN (?,?) AND task.`id` >= ? AND is_deleted = ?",
+		"2018-01-01", "2018-02-01", `%50\%\_off%`, 1, 2, 10, 0)
+	s = table.Where(func(s *Search) {
+		s.Eq("hospital.name", "a").Or("state = ?", 2)
+	}).Joins("hospital", "hospital.id = task.hospital_id AND hospital.id > 0").Search
+	assertParse(t, s, "SELECT * FROM task LEFT JOIN hospital ON hospital.id = task.hospital_id AND hospital.id > 0 WHERE (hospital.`name` = ? OR state = ?) AND is_deleted = ?", "a", 2, 0)
+
+	s = table.Eq("nmae", "x").Search
+	var e *Error
+	if err := s.Rows().Err(); !errors.As(err, &e) || e.Err != ErrUnknownColumn || e.Column != "nmae" {
+		t.Fatalf("err = %v, want ErrUnknownColumn", err)
+	}
+}
+
+func TestSearchSubQuery(t *testing.T) {
+	db := newTestDataBase()
+	hospitals := db.Table("hospital").Fields("id").Where("name LIKE ?", "a%").Search
+	s := db.Table("task").Where("state = ?", 1).InSub("hospital_id", hospitals).WhereNotExists(db.Table("hospital").Where("hospital.id = task.hospital_id").Search).Search
+	assertParse(t, s, "SELECT * FROM task WHERE state = ? AND `hospital_id` IN (SELECT `id` FROM hospital WHERE name LIKE ?) AND NOT EXISTS (SELECT * FROM hospital WHERE hospital.id = task.hospital_id) AND is_deleted = ?", 1, "a%", 0)
+
+	if err := db.Table("task").NotInSub("hospital", hospitals).Err(); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("NotInSub unknown column err = %v, want ErrUnknownColumn", err)
+	}
+	assertParse(t, db.Table("task").NotInSub("task.hospital_id", hospitals).Search,
+		"SELECT * FROM task WHERE task.`hospital_id` NOT IN (SELECT `id` FROM hospital WHERE name LIKE ?) AND is_deleted = ?", "a%", 0)
+
+	s = db.Table("task").From(db.Table("hospital").Where("id > ?", 1).Search, "h").Where("h.name = ?", "x").Limit(1).Search
+	assertParse(t, s, "SELECT * FROM (SELECT * FROM hospital WHERE id > ?) AS `h` WHERE h.name = ? LIMIT ?", 1, "x", 1)
+}
+
+func TestSearchUnion(t *testing.T) {
+	db := newTestDataBase()
+	archive := db.Table("task_archive").Fields("id", "name").Where("state = ?", 2).Search
+	s := db.Table("hospital").Fields("id", "name").Where("id > ?", 1).UnionAll(archive).OrderDesc("id").Limit(20).Search
+	assertParse(t, s, "(SELECT `id`,`name` FROM hospital WHERE id > ?) UNION ALL (SELECT `id`,`name` FROM task_archive WHERE state = ?) ORDER BY `id` DESC LIMIT ?", 1, 2, 20)
+}
+
+func TestSearchUpdateAllNeedCondition(t *testing.T) {
+	table := newTestDataBase().Table("task")
+	if _, err := table.UpdateAll(map[string]interface{}{"state": 2}); err != ErrNoCondition {
+		t.Fatalf("err = %v, want ErrNoCondition", err)
+	}
+	if _, err := table.DeleteAll(); err != ErrNoCondition {
+		t.Fatalf("err = %v, want ErrNoCondition", err)
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	db, state := newStubDataBase(t)
+	db.tableColumns["task"]["updated_at"] = Column{Name: "updated_at"}
+	db.tableColumns["task"]["deleted_at"] = Column{Name: "deleted_at"}
+	last := func() string {
+		statements := state.statements()
+		return statements[len(statements)-1]
+	}
+
+	if _, err := db.Table("task").Where("state = ?", 1).UpdateAll(map[string]interface{}{"state": 2, "name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := last(), "UPDATE `task` SET `task`.`name` = ?, `task`.`state` = ?, `task`.`updated_at` = ? WHERE state = ? AND is_deleted = ?"; got != want {
+		t.Errorf("update\n got: %s\nwant: %s", got, want)
+	}
+	if args := state.lastArgs(); len(args) != 5 || args[0] != "a" || args[1] != int64(2) || args[3] != int64(1) {
+		t.Errorf("update args = %v", args)
+	}
+
+	if _, err := db.Table("task").Eq("state", 1).DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := last(), "UPDATE `task` SET `task`.`is_deleted` = 1, `task`.`deleted_at` = ? WHERE `state` = ? AND is_deleted = ?"; got != want {
+		t.Errorf("soft delete\n got: %s\nwant: %s", got, want)
+	}
+
+	if _, err := db.Table("hospital").Where("name = ?", "a").OrderAsc("id").Limit(10).DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := last(), "DELETE FROM `hospital` WHERE name = ? ORDER BY `id` ASC LIMIT ?"; got != want {
+		t.Errorf("delete\n got: %s\nwant: %s", got, want)
+	}
+
+	if _, err := db.Table("hospital").Eq("task.state", 3).DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := last(), "DELETE `hospital` FROM `hospital` LEFT JOIN task ON task.hospital_id = hospital.id WHERE task.`state` = ?"; got != want {
+		t.Errorf("multi-table delete\n got: %s\nwant: %s", got, want)
+	}
+
+	if _, err := db.Table("hospital").Force().DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := last(), "DELETE FROM `hospital`"; got != want {
+		t.Errorf("force delete\n got: %s\nwant: %s", got, want)
+	}
+
+	executed := len(state.statements())
+	if _, err := db.Table("hospital").Joins("task").Where("task.state = ?", 1).Limit(1).DeleteAll(); err != ErrArgs {
+		t.Errorf("multi-table delete with LIMIT err = %v, want ErrArgs", err)
+	}
+	if _, err := db.Table("task").Force().UpdateAll(map[string]interface{}{"name`=1,`state": 2}); err != ErrArgs {
+		t.Errorf("bad key err = %v, want ErrArgs", err)
+	}
+	if _, err := db.Table("task").Force().UpdateAll(map[string]interface{}{"nmae": 2}); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("unknown key err = %v, want ErrUnknownColumn", err)
+	}
+	if n := len(state.statements()); n != executed {
+		t.Errorf("rejected batches executed %v", state.statements()[executed:])
+	}
+}
+
+func TestSearchImmutable(t *testing.T) {
+	base := newTestDataBase().Table("task").Fields("task.name", "hospital.name AS hospital_name").Where("state = ?", 1)
+	a := base.Where("id = ?", 2)
+	b := base.OrderDesc("id")
+	for i := 0; i < 2; i++ {
+		assertParse(t, base.Search, "SELECT task.`name`,hospital.`name` AS hospital_name FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE state = ? AND is_deleted = ?", 1, 0)
+	}
+	assertParse(t, a.Search, "SELECT task.`name`,hospital.`name` AS hospital_name FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE state = ? AND id = ? AND is_deleted = ?", 1, 2, 0)
+	assertParse(t, b.Search, "SELECT task.`name`,hospital.`name` AS hospital_name FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE state = ? AND is_deleted = ? ORDER BY task.`id` DESC", 1, 0)
+	if len(base.joinConditions) != 0 || base.fields[0] != "task.name" {
+		t.Fatal("Parse must not modify the Search")
+	}
+}
+
+func TestSearchCountQuery(t *testing.T) {
+	table := newTestDataBase().Table("task").Where("state = ?", 1).OrderDesc("id").Limit(10).Offset(20)
+	query, args := table.countQuery()
+	if query != "SELECT COUNT(*) FROM task WHERE state = ? AND is_deleted = ?" || !reflect.DeepEqual(args, []interface{}{1, 0}) {
+		t.Errorf("count query %s %v", query, args)
+	}
+	query, _ = table.Fields("hospital_id").Group("hospital_id").countQuery()
+	if query != "SELECT COUNT(*) FROM (SELECT `hospital_id` FROM task WHERE state = ? AND is_deleted = ? GROUP BY `hospital_id`) AS `crud_aggregate`" {
+		t.Errorf("count query %s", query)
+	}
+	query, _ = table.Group("hospital_id").countQuery()
+	if query != "SELECT COUNT(*) FROM (SELECT `hospital_id` FROM task WHERE state = ? AND is_deleted = ? GROUP BY `hospital_id`) AS `crud_aggregate`" {
+		t.Errorf("group count query %s", query)
+	}
+	query, args = newTestDataBase().Table("task").Fields("task.id", "hospital.name").Where("hospital.name = ?", "a").countQuery()
+	if query != "SELECT COUNT(*) FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE hospital.name = ? AND is_deleted = ?" || !reflect.DeepEqual(args, []interface{}{"a", 0}) {
+		t.Errorf("joined count query %s %v", query, args)
+	}
+	if p := newPage(21, 3, 10); p.Pages != 3 {
+		t.Errorf("pages = %d, want 3", p.Pages)
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	table := newTestDataBase().Table("task").OrderDesc("created_at").Limit(20)
+	rows := RowsMap{{"id": "7", "created_at": "2018-01-02 00:00:00"}, {"id": "5", "created_at": "2018-01-01 00:00:00"}}
+	next := table.NextCursor(rows)
+	assertParse(t, table.After(next).Search,
+		"SELECT * FROM task WHERE is_deleted = ? AND (`created_at`, task.`id`) < (?,?) ORDER BY `created_at` DESC, task.`id` DESC LIMIT ?",
+		0, "2018-01-01 00:00:00", "5", 20)
+	assertParse(t, table.Before(table.PrevCursor(rows)).Search,
+		"SELECT * FROM task WHERE is_deleted = ? AND (`created_at`, task.`id`) > (?,?) ORDER BY `created_at` ASC, task.`id` ASC LIMIT ?",
+		0, "2018-01-02 00:00:00", "7", 20)
+
+	first := table.Limit(2).After("")
+	assertParse(t, first.Search, "SELECT * FROM task WHERE is_deleted = ? ORDER BY `created_at` DESC, task.`id` DESC LIMIT ?", 0, 2)
+	assertParse(t, table.Limit(2).After(first.NextCursor(rows)).Search,
+		"SELECT * FROM task WHERE is_deleted = ? AND (`created_at`, task.`id`) < (?,?) ORDER BY `created_at` DESC, task.`id` DESC LIMIT ?",
+		0, "2018-01-01 00:00:00", "5", 2)
+	assertParse(t, table.Before("").Search, "SELECT * FROM task WHERE is_deleted = ? ORDER BY `created_at` ASC, task.`id` ASC LIMIT ?", 0, 20)
+
+	joined := newTestDataBase().Table("task").OrderAsc("hospital.id").After("")
+	assertParse(t, joined.Search, "SELECT * FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE is_deleted = ? ORDER BY hospital.`id` ASC, task.`id` ASC", 0)
+	assertParse(t, newTestDataBase().Table("task").Order("task.id DESC").After("").Search, "SELECT * FROM task WHERE is_deleted = ? ORDER BY task.`id` DESC", 0)
+
+	if err := table.After("!!").Rows().Err(); err != ErrCursor {
+		t.Fatalf("err = %v, want ErrCursor", err)
+	}
+}
+
+func TestSearchLock(t *testing.T) {
+	table := newTestDataBase().Table("hospital").Where("id = ?", 1).ForUpdate().SkipLocked()
+	assertParse(t, table.Search, "SELECT * FROM hospital WHERE id = ? FOR UPDATE SKIP LOCKED", 1)
+	if err := table.Rows().Err(); err != ErrNeedTransaction {
+		t.Fatalf("err = %v, want ErrNeedTransaction", err)
+	}
+}
+
+func TestSearchAggregateQuery(t *testing.T) {
+	table := newTestDataBase().Table("task").Where("state = ?", 1).OrderDesc("id").Limit(10)
+	query, args := table.aggregateQuery("SUM(`id`)", "SUM(`id`)", "")
+	if query != "SELECT SUM(`id`) FROM task WHERE state = ? AND is_deleted = ?" || !reflect.DeepEqual(args, []interface{}{1, 0}) {
+		t.Errorf("aggregate query %s %v", query, args)
+	}
+	if _, err := table.Sum("amount"); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("err = %v, want ErrUnknownColumn", err)
+	}
+
+	query, args, err := table.aggregateFieldQuery("MAX", false, "hospital.name")
+	if err != nil || query != "SELECT MAX(hospital.`name`) FROM task LEFT JOIN hospital ON task.hospital_id = hospital.id WHERE state = ? AND is_deleted = ?" || !reflect.DeepEqual(args, []interface{}{1, 0}) {
+		t.Errorf("joined aggregate query %s %v %v", query, args, err)
+	}
+	query, _, _ = table.Fields("DISTINCT hospital_id").aggregateFieldQuery("COUNT", true, "hospital_id")
+	if query != "SELECT COUNT(DISTINCT `hospital_id`) FROM (SELECT DISTINCT `hospital_id` FROM task WHERE state = ? AND is_deleted = ?) AS `crud_aggregate`" {
+		t.Errorf("distinct aggregate query %s", query)
+	}
+
+	db := newTestDataBase()
+	archive := db.Table("task_archive").Where("state = ?", 2).Search
+	query, args = db.Table("task").Where("state = ?", 1).UnionAll(archive).Limit(5).existsQuery()
+	if query != "SELECT EXISTS(SELECT 1 FROM ((SELECT * FROM task WHERE state = ? AND is_deleted = ?) UNION ALL (SELECT * FROM task_archive WHERE state = ?)) AS `crud_aggregate`)" || !reflect.DeepEqual(args, []interface{}{1, 0, 2}) {
+		t.Errorf("union exists query %s %v", query, args)
+	}
+	query, _ = db.Table("task").Fields("hospital_id").Group("hospital_id").Having("COUNT(*) > ?", 1).existsQuery()
+	if query != "SELECT EXISTS(SELECT 1 FROM (SELECT `hospital_id` FROM task WHERE is_deleted = ? GROUP BY `hospital_id` HAVING COUNT(*) > ?) AS `crud_aggregate`)" {
+		t.Errorf("group exists query %s", query)
+	}
+	query, _ = db.Table("hospital").Eq("name", "a").existsQuery()
+	if query != "SELECT EXISTS(SELECT 1 FROM hospital WHERE `name` = ?)" {
+		t.Errorf("exists query %s", query)
+	}
+}
+
+func TestSearchExistsErr(t *testing.T) {
+	db, state := newStubDataBase(t)
+	state.columns = []string{"exists"}
+	state.rows = [][]driver.Value{{int64(1)}}
+	if exists, err := db.Table("task").Eq("state", 1).ExistsErr(); err != nil || !exists {
+		t.Errorf("exists = %v, err = %v", exists, err)
+	}
+	if exists, err := db.Table("task").Eq("nmae", 1).ExistsErr(); exists || !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("exists = %v, err = %v, want ErrUnknownColumn", exists, err)
+	}
+	if _, err := db.Table("task").ForUpdate().ExistsErr(); err != ErrNeedTransaction {
+		t.Errorf("err = %v, want ErrNeedTransaction", err)
+	}
+	state.fail = "EXISTS"
+	if exists, err := db.Table("task").ExistsErr(); exists || err != errStub {
+		t.Errorf("exists = %v, err = %v, want errStub", exists, err)
+	}
+	if db.Table("task").Exists() {
+		t.Error("Exists should be false on error")
+	}
+}
+
+func TestSearchScope(t *testing.T) {
+	db := newTestDataBase()
+	db.RegisterScope("task", "active", func(s *Search) *Search {
+		return s.Eq("state", 1)
+	})
+	hospital := func(s *Search) *Search {
+		return s.Eq("hospital_id", 2)
+	}
+	table := db.Table("task")
+	assertParse(t, table.Scope("active").Scopes(hospital).Search, "SELECT * FROM task WHERE `state` = ? AND `hospital_id` = ? AND is_deleted = ?", 1, 2, 0)
+	assertParse(t, table.Search, "SELECT * FROM task WHERE is_deleted = ?", 0)
+	if err := table.Scope("missing").Err(); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("err = %v, want ErrUnknownScope", err)
+	}
+	if err := db.Table("hospital").Scope("active").Err(); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("scope should be registered per table, err = %v", err)
+	}
+
+	literal := &DataBase{}
+	if err := literal.Table("task").Scope("active").Err(); !errors.Is(err, ErrUnknownScope) {
+		t.Errorf("err = %v, want ErrUnknownScope", err)
+	}
+	literal.RegisterScope("task", "active", func(s *Search) *Search {
+		return s.Where("state = ?", 1)
+	})
+	assertParse(t, literal.Table("task").Scope("active").Search, "SELECT * FROM task WHERE state = ?", 1)
+}
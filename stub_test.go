@@ -0,0 +1,177 @@
+package crud
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubDriver 用于测试的database/sql驱动，不连接MySQL。
+// 记录执行过的语句，所有查询都返回同一份columns/rows。
+type stubDriver struct{}
+
+type stubState struct {
+	mu      sync.Mutex
+	log     []string
+	args    [][]driver.Value //每条语句的参数，和log一一对应
+	columns []string
+	rows    [][]driver.Value
+	fail    string //语句中包含fail的时候返回errStub
+	id      int64
+	affect  int64
+}
+
+var (
+	errStub    = errors.New("stub error")
+	stubStates sync.Map
+	stubSeq    int
+	stubMu     sync.Mutex
+)
+
+func init() {
+	sql.Register("crud_stub", stubDriver{})
+}
+
+// newStubDataBase 返回一个使用stub驱动的DataBase，表结构和newTestDataBase一样。
+func newStubDataBase(t *testing.T) (*DataBase, *stubState) {
+	t.Helper()
+	stubMu.Lock()
+	stubSeq++
+	name := fmt.Sprintf("stub%d", stubSeq)
+	stubMu.Unlock()
+	state := &stubState{id: 1, affect: 1}
+	stubStates.Store(name, state)
+	sqlDB, err := sql.Open("crud_stub", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db := newTestDataBase()
+	db.db = sqlDB
+	return db, state
+}
+
+func (s *stubState) record(query string, args ...driver.NamedValue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, query)
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	s.args = append(s.args, values)
+	if s.fail != "" && strings.Contains(query, s.fail) {
+		return errStub
+	}
+	return nil
+}
+
+// statements 返回执行过的语句
+func (s *stubState) statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.log...)
+}
+
+// lastArgs 返回最后一条语句的参数
+func (s *stubState) lastArgs() []driver.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.args) == 0 {
+		return nil
+	}
+	return s.args[len(s.args)-1]
+}
+
+func (stubDriver) Open(name string) (driver.Conn, error) {
+	state, ok := stubStates.Load(name)
+	if !ok {
+		return nil, errors.New("unknown stub " + name)
+	}
+	return &stubConn{state: state.(*stubState)}, nil
+}
+
+type stubConn struct {
+	state *stubState
+}
+
+func (c *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return &stubStmt{conn: c, query: query}, nil
+}
+
+func (c *stubConn) Close() error { return nil }
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	if err := c.state.record("BEGIN"); err != nil {
+		return nil, err
+	}
+	return &stubTx{state: c.state}, nil
+}
+
+func (c *stubConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.state.record(query, args...); err != nil {
+		return nil, err
+	}
+	return stubResult{id: c.state.id, affect: c.state.affect}, nil
+}
+
+func (c *stubConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.state.record(query, args...); err != nil {
+		return nil, err
+	}
+	return &stubRows{columns: c.state.columns, rows: c.state.rows}, nil
+}
+
+type stubStmt struct {
+	conn  *stubConn
+	query string
+}
+
+func (s *stubStmt) Close() error  { return nil }
+func (s *stubStmt) NumInput() int { return -1 }
+
+func (s *stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s *stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, nil)
+}
+
+type stubTx struct {
+	state *stubState
+}
+
+func (tx *stubTx) Commit() error   { return tx.state.record("COMMIT") }
+func (tx *stubTx) Rollback() error { return tx.state.record("ROLLBACK") }
+
+type stubResult struct {
+	id, affect int64
+}
+
+func (r stubResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r stubResult) RowsAffected() (int64, error) { return r.affect, nil }
+
+type stubRows struct {
+	columns []string
+	rows    [][]driver.Value
+	i       int
+}
+
+func (r *stubRows) Columns() []string { return r.columns }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
@@ -1,6 +1,7 @@
 package crud
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -76,7 +77,11 @@ func (t *Table) Create(m map[string]interface{}, checks ...string) (int64, error
 			values = append(values, m[check])
 		}
 		// SELECT COUNT(*) FROM `feedback` WHERE `task_id` = ? AND `member_id` = ?
-		if t.Query(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", t.tableName, strings.Join(names, "AND")), values...).Int() > 0 {
+		var count int
+		if err := t.Query(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", t.tableName, strings.Join(names, "AND")), values...).Scan(&count); err != nil {
+			return 0, err
+		}
+		if count > 0 {
 			return 0, ErrInsertRepeat
 		}
 	}
@@ -84,12 +89,12 @@ func (t *Table) Create(m map[string]interface{}, checks ...string) (int64, error
 		m[CreatedAt] = time.Now().Format(TimeFormat)
 	}
 	ks, vs := ksvs(m)
-	id, err := t.Exec(fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)", t.tableName, strings.Join(ks, ","), argslice(len(ks))), vs...).LastInsertId()
+	id, err := t.Exec(fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)", t.tableName, strings.Join(ks, ","), argslice(len(ks))), vs...).ID()
 	if err != nil {
-		return 0, errors.New("SQL语句异常")
+		return 0, convertError(err, t.tableName)
 	}
 	if id <= 0 {
-		return 0, errors.New("插入数据库异常")
+		return 0, ErrInsertData
 	}
 	return id, nil
 }
@@ -126,7 +131,7 @@ func (t *Table) Update(m map[string]interface{}, keys ...string) error {
 	for _, key := range keys {
 		val, ok := m[key]
 		if !ok {
-			return errors.New("没有更新主键")
+			return ErrNoUpdateKey
 		}
 		keysValue = append(keysValue, val)
 		delete(m, key)
@@ -138,18 +143,16 @@ func (t *Table) Update(m map[string]interface{}, keys ...string) error {
 	for _, val := range keysValue {
 		vs = append(vs, val)
 	}
-	_, err := t.Exec(fmt.Sprintf("UPDATE `%s` SET %s WHERE %s LIMIT 1", t.tableName, strings.Join(ks, ","), strings.Join(whereks, "AND")), vs...).RowsAffected()
-	if err != nil {
-		return errors.New("SQL语句异常")
-	}
-	return nil
+	err := t.Exec(fmt.Sprintf("UPDATE `%s` SET %s WHERE %s LIMIT 1", t.tableName, strings.Join(ks, ","), strings.Join(whereks, "AND")), vs...).Err()
+	return convertError(err, t.tableName)
 }
 
 //CreateOrUpdate 创建或者更新
+//先查询再插入，并发时可能重复插入，推荐使用Upsert。
 func (t *Table) CreateOrUpdate(m map[string]interface{}, keys ...string) error {
 	_, err := t.Create(m, keys...)
 	if err != nil {
-		if err == ErrInsertRepeat {
+		if errors.Is(err, ErrInsertRepeat) {
 			return t.Update(m, keys...)
 		}
 		return err
@@ -159,11 +162,15 @@ func (t *Table) CreateOrUpdate(m map[string]interface{}, keys ...string) error {
 
 // Delete 删除
 func (t *Table) Delete(m map[string]interface{}) (int64, error) {
+	var ret *SQLResult
 	ks, vs := ksvs(m, " = ? ")
 	if t.tableColumns[t.tableName].HaveColumn(IsDeleted) {
-		return t.Exec(fmt.Sprintf("UPDATE `%s` SET is_deleted = '1', deleted_at = '%s' WHERE %s", t.tableName, time.Now().Format(TimeFormat), strings.Join(ks, "AND")), vs...).RowsAffected()
+		ret = t.Exec(fmt.Sprintf("UPDATE `%s` SET is_deleted = '1', deleted_at = '%s' WHERE %s", t.tableName, time.Now().Format(TimeFormat), strings.Join(ks, "AND")), vs...)
+	} else {
+		ret = t.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", t.tableName, strings.Join(ks, "AND")), vs...)
 	}
-	return t.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", t.tableName, strings.Join(ks, "AND")), vs...).RowsAffected()
+	affected, err := ret.RowsAffected()
+	return affected, convertError(err, t.tableName)
 }
 
 // Clone 克隆
@@ -182,27 +189,240 @@ func (t *Table) Clone() *Table {
 	return newTable
 }
 
+// chain 返回一个使用search的新Table，search是链式调用返回的新Search。
+func (t *Table) chain(search *Search) *Table {
+	newTable := &Table{
+		DataBase:  t.DataBase,
+		Search:    search,
+		tableName: t.tableName,
+	}
+	search.table = newTable
+	return newTable
+}
+
+// WithContext 返回一个使用ctx执行语句的Table
+func (t *Table) WithContext(ctx context.Context) *Table {
+	newTable := t.Clone()
+	newTable.DataBase = t.DataBase.WithContext(ctx)
+	return newTable
+}
+
 //Where where
-func (t *Table) Where(query string, args ...interface{}) *Table {
-	return t.Clone().Search.Where(query, args...).table
+func (t *Table) Where(query interface{}, args ...interface{}) *Table {
+	return t.chain(t.Search.Where(query, args...))
+}
+
+//Or or
+func (t *Table) Or(query interface{}, args ...interface{}) *Table {
+	return t.chain(t.Search.Or(query, args...))
+}
+
+//Not not
+func (t *Table) Not(query interface{}, args ...interface{}) *Table {
+	return t.chain(t.Search.Not(query, args...))
 }
 
 //In In
 func (t *Table) In(field string, args ...interface{}) *Table {
-	return t.Clone().Search.In(field, args...).table
+	return t.chain(t.Search.In(field, args...))
+}
+
+//NotIn not in
+func (t *Table) NotIn(field string, args ...interface{}) *Table {
+	return t.chain(t.Search.NotIn(field, args...))
+}
+
+//Eq =
+func (t *Table) Eq(field string, value interface{}) *Table {
+	return t.chain(t.Search.Eq(field, value))
+}
+
+//Ne <>
+func (t *Table) Ne(field string, value interface{}) *Table {
+	return t.chain(t.Search.Ne(field, value))
+}
+
+//Gt >
+func (t *Table) Gt(field string, value interface{}) *Table {
+	return t.chain(t.Search.Gt(field, value))
+}
+
+//Gte >=
+func (t *Table) Gte(field string, value interface{}) *Table {
+	return t.chain(t.Search.Gte(field, value))
+}
+
+//Lt <
+func (t *Table) Lt(field string, value interface{}) *Table {
+	return t.chain(t.Search.Lt(field, value))
+}
+
+//Lte <=
+func (t *Table) Lte(field string, value interface{}) *Table {
+	return t.chain(t.Search.Lte(field, value))
+}
+
+//Between between
+func (t *Table) Between(field string, start, end interface{}) *Table {
+	return t.chain(t.Search.Between(field, start, end))
+}
+
+//IsNull is null
+func (t *Table) IsNull(field string) *Table {
+	return t.chain(t.Search.IsNull(field))
+}
+
+//NotNull is not null
+func (t *Table) NotNull(field string) *Table {
+	return t.chain(t.Search.NotNull(field))
+}
+
+//Like like
+func (t *Table) Like(field string, pattern string) *Table {
+	return t.chain(t.Search.Like(field, pattern))
+}
+
+//StartsWith like value%
+func (t *Table) StartsWith(field string, value string) *Table {
+	return t.chain(t.Search.StartsWith(field, value))
+}
+
+//EndsWith like %value
+func (t *Table) EndsWith(field string, value string) *Table {
+	return t.chain(t.Search.EndsWith(field, value))
+}
+
+//Contains like %value%
+func (t *Table) Contains(field string, value string) *Table {
+	return t.chain(t.Search.Contains(field, value))
+}
+
+//InSub in (select ...)
+func (t *Table) InSub(field string, sub *Search) *Table {
+	return t.chain(t.Search.InSub(field, sub))
+}
+
+//NotInSub not in (select ...)
+func (t *Table) NotInSub(field string, sub *Search) *Table {
+	return t.chain(t.Search.NotInSub(field, sub))
+}
+
+//WhereExists exists (select ...)
+func (t *Table) WhereExists(sub *Search) *Table {
+	return t.chain(t.Search.WhereExists(sub))
+}
+
+//WhereNotExists not exists (select ...)
+func (t *Table) WhereNotExists(sub *Search) *Table {
+	return t.chain(t.Search.WhereNotExists(sub))
+}
+
+//From from (select ...) as alias
+func (t *Table) From(sub *Search, alias string) *Table {
+	return t.chain(t.Search.From(sub, alias))
+}
+
+//Union union
+func (t *Table) Union(other *Search) *Table {
+	return t.chain(t.Search.Union(other))
+}
+
+//UnionAll union all
+func (t *Table) UnionAll(other *Search) *Table {
+	return t.chain(t.Search.UnionAll(other))
+}
+
+//Force 允许UpdateAll、DeleteAll没有条件
+func (t *Table) Force() *Table {
+	return t.chain(t.Search.Force())
 }
 
 //Joins joins
 func (t *Table) Joins(query string, args ...string) *Table {
-	return t.Clone().Search.Joins(query, args...).table
+	return t.chain(t.Search.Joins(query, args...))
+}
+
+//Order order
+func (t *Table) Order(query string) *Table {
+	return t.chain(t.Search.Order(query))
+}
+
+//OrderAsc order asc
+func (t *Table) OrderAsc(fields ...string) *Table {
+	return t.chain(t.Search.OrderAsc(fields...))
+}
+
+//OrderDesc order desc
+func (t *Table) OrderDesc(fields ...string) *Table {
+	return t.chain(t.Search.OrderDesc(fields...))
+}
+
+//Group group by
+func (t *Table) Group(query string) *Table {
+	return t.chain(t.Search.Group(query))
+}
+
+//Having having
+func (t *Table) Having(query string, args ...interface{}) *Table {
+	return t.chain(t.Search.Having(query, args...))
+}
+
+//With with
+func (t *Table) With(name string, search *Search) *Table {
+	return t.chain(t.Search.With(name, search))
 }
 
 //Limit limit
 func (t *Table) Limit(n interface{}) *Table {
-	return t.Clone().Search.Limit(n).table
+	return t.chain(t.Search.Limit(n))
+}
+
+//Offset offset
+func (t *Table) Offset(n interface{}) *Table {
+	return t.chain(t.Search.Offset(n))
+}
+
+//After 游标之后
+func (t *Table) After(cursor string) *Table {
+	return t.chain(t.Search.After(cursor))
+}
+
+//Before 游标之前
+func (t *Table) Before(cursor string) *Table {
+	return t.chain(t.Search.Before(cursor))
+}
+
+//ForUpdate for update
+func (t *Table) ForUpdate() *Table {
+	return t.chain(t.Search.ForUpdate())
+}
+
+//ForShare for share
+func (t *Table) ForShare() *Table {
+	return t.chain(t.Search.ForShare())
+}
+
+//SkipLocked skip locked
+func (t *Table) SkipLocked() *Table {
+	return t.chain(t.Search.SkipLocked())
+}
+
+//NoWait nowait
+func (t *Table) NoWait() *Table {
+	return t.chain(t.Search.NoWait())
+}
+
+//Scopes scopes
+func (t *Table) Scopes(fns ...ScopeFunc) *Table {
+	return t.chain(t.Search.Scopes(fns...))
+}
+
+//Scope 使用注册过的scope
+func (t *Table) Scope(names ...string) *Table {
+	return t.chain(t.Search.Scope(names...))
 }
 
 //Fields fields
 func (t *Table) Fields(args ...string) *Table {
-	return t.Clone().Search.Fields(args...).table
+	return t.chain(t.Search.Fields(args...))
 }
@@ -0,0 +1,220 @@
+package crud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// 事务相关的错误
+var (
+	ErrTxDone             = errors.New("事务已经结束")
+	ErrTxStarted          = errors.New("已经在事务中，嵌套请使用Transaction或SavePoint")
+	ErrSavePointNotFound  = errors.New("保存点不存在")
+	ErrSavePointReleased  = errors.New("保存点已经释放")
+	ErrSavePointDuplicate = errors.New("保存点已经存在")
+)
+
+// executor *sql.DB和*sql.Tx都实现了这个接口，DataBase通过它来执行语句。
+type executor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Tx 事务
+/*
+	Tx拥有和DataBase一样的CRUD、Table、Search方法，所有的语句都在同一个*sql.Tx中执行。
+	钩子函数如果声明为 func(db *DataBase) error ，拿到的db也是在这个事务中的。
+
+	tx, err := db.Begin()
+	tx.Table("task").Create(m)
+	tx.Create(&member)
+	tx.Commit()
+*/
+type Tx struct {
+	*DataBase
+	tx   *sql.Tx
+	done bool
+
+	savePoints []string        //当前还有效的保存点，按创建顺序排列
+	released   map[string]bool //已经释放过的保存点
+	seq        int             //嵌套Transaction自动生成保存点名字用
+}
+
+// Begin 开始一个事务，如果db有context，事务也使用这个context。
+func (db *DataBase) Begin() (*Tx, error) {
+	if db.db == nil {
+		return nil, ErrNoConnection
+	}
+	if db.tx != nil {
+		return nil, ErrTxStarted
+	}
+	db.Log("BEGIN")
+	sqlTx, err := db.db.BeginTx(db.context(), nil)
+	if err != nil {
+		return nil, err
+	}
+	tx := &Tx{tx: sqlTx, released: make(map[string]bool)}
+	txdb := *db
+	txdb.tx = tx
+	tx.DataBase = &txdb
+	return tx, nil
+}
+
+// Commit 提交事务
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.Log("COMMIT")
+	tx.done = true
+	return tx.tx.Commit()
+}
+
+// Rollback 回滚事务
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.Log("ROLLBACK")
+	tx.done = true
+	return tx.tx.Rollback()
+}
+
+// SQLTx 返回底层的*sql.Tx
+func (tx *Tx) SQLTx() *sql.Tx {
+	return tx.tx
+}
+
+// Transaction 在一个事务中执行fn
+// fn返回error或者panic的时候回滚，否则提交。panic会在回滚后继续抛出。
+// 如果db已经在事务中，则使用保存点嵌套，见(*Tx).Transaction。
+func (db *DataBase) Transaction(fn func(tx *Tx) error) error {
+	if db.tx != nil {
+		return db.tx.Transaction(fn)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+	SAVEPOINT
+*/
+
+// SavePoint 设置一个保存点 SAVEPOINT name
+// name只能是字母、数字和下划线，否则返回ErrArgs。
+func (tx *Tx) SavePoint(name string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if !isIdentifier(name) {
+		return ErrArgs
+	}
+	if tx.savePointIndex(name) >= 0 {
+		return ErrSavePointDuplicate
+	}
+	if err := tx.Exec("SAVEPOINT `" + name + "`").Err(); err != nil {
+		return err
+	}
+	tx.savePoints = append(tx.savePoints, name)
+	delete(tx.released, name)
+	return nil
+}
+
+// RollbackTo 回滚到保存点 ROLLBACK TO SAVEPOINT name
+// 回滚后这个保存点仍然有效，在它之后设置的保存点会被MySQL删除。
+func (tx *Tx) RollbackTo(name string) error {
+	i, err := tx.lookupSavePoint(name)
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec("ROLLBACK TO SAVEPOINT `" + name + "`").Err(); err != nil {
+		return err
+	}
+	tx.savePoints = tx.savePoints[:i+1]
+	return nil
+}
+
+// Release 释放保存点 RELEASE SAVEPOINT name
+// 在它之后设置的保存点也会一起被释放，同一个保存点释放两次会返回ErrSavePointReleased。
+func (tx *Tx) Release(name string) error {
+	i, err := tx.lookupSavePoint(name)
+	if err != nil {
+		return err
+	}
+	if err := tx.Exec("RELEASE SAVEPOINT `" + name + "`").Err(); err != nil {
+		return err
+	}
+	for _, sp := range tx.savePoints[i:] {
+		tx.released[sp] = true
+	}
+	tx.savePoints = tx.savePoints[:i]
+	return nil
+}
+
+// Transaction 嵌套事务，使用保存点实现
+// fn返回error或者panic的时候只回滚到这次设置的保存点，外层的事务可以继续执行。
+func (tx *Tx) Transaction(fn func(tx *Tx) error) error {
+	tx.seq++
+	name := fmt.Sprintf("crud_sp_%d", tx.seq)
+	if err := tx.SavePoint(name); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.rollbackSavePoint(name)
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.rollbackSavePoint(name)
+		return err
+	}
+	return tx.Release(name)
+}
+
+func (tx *Tx) rollbackSavePoint(name string) {
+	if tx.RollbackTo(name) == nil {
+		tx.Release(name)
+	}
+}
+
+func (tx *Tx) lookupSavePoint(name string) (int, error) {
+	if tx.done {
+		return -1, ErrTxDone
+	}
+	if !isIdentifier(name) {
+		return -1, ErrArgs
+	}
+	i := tx.savePointIndex(name)
+	if i < 0 {
+		if tx.released[name] {
+			return -1, ErrSavePointReleased
+		}
+		return -1, ErrSavePointNotFound
+	}
+	return i, nil
+}
+
+func (tx *Tx) savePointIndex(name string) int {
+	for i, sp := range tx.savePoints {
+		if sp == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,170 @@
+package crud
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var hookDataBase *DataBase
+
+type hookTask struct {
+	ID   int
+	Name string
+}
+
+func (hookTask) DBName() string { return "task" }
+
+func (h *hookTask) BeforeCreate(db *DataBase) error {
+	hookDataBase = db
+	return nil
+}
+
+func TestTxExecutor(t *testing.T) {
+	db, state := newStubDataBase(t)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.DataBase.executor() != executor(tx.SQLTx()) {
+		t.Error("tx.DataBase should use the *sql.Tx executor")
+	}
+	search := tx.Table("task").Where("state = ?", 1).OrderDesc("id").Search
+	if search.table.tx != tx || search.table.executor() != executor(tx.SQLTx()) {
+		t.Error("Table and Search from Tx should use the *sql.Tx executor")
+	}
+	if db.tx != nil || db.executor() != executor(db.db) {
+		t.Error("Begin should not change the original DataBase")
+	}
+	if _, err := tx.Begin(); err != ErrTxStarted {
+		t.Errorf("nested Begin err = %v, want ErrTxStarted", err)
+	}
+
+	hookDataBase = nil
+	if _, err := tx.Create(&hookTask{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if hookDataBase == nil || hookDataBase.tx != tx {
+		t.Error("hook should get the DataBase bound to the transaction")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Errorf("second Commit err = %v, want ErrTxDone", err)
+	}
+	got := state.statements()
+	if len(got) != 3 || got[0] != "BEGIN" || !strings.HasPrefix(got[1], "INSERT INTO `task`") || got[2] != "COMMIT" {
+		t.Errorf("statements = %q, want BEGIN, INSERT, COMMIT", got)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	db, state := newStubDataBase(t)
+	errBoom := errors.New("boom")
+	if err := db.Transaction(func(tx *Tx) error { return errBoom }); err != errBoom {
+		t.Errorf("err = %v, want errBoom", err)
+	}
+	func() {
+		defer func() {
+			if p := recover(); p != "panic" {
+				t.Errorf("recover = %v, want the panic to be rethrown", p)
+			}
+		}()
+		db.Transaction(func(tx *Tx) error { panic("panic") })
+	}()
+	if err := db.Transaction(func(tx *Tx) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "ROLLBACK", "BEGIN", "ROLLBACK", "BEGIN", "COMMIT"}
+	if got := state.statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("statements\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestSavePointStack(t *testing.T) {
+	db, _ := newStubDataBase(t)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := tx.SavePoint(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"", "a`; DROP TABLE task; -- ", "a b"} {
+		if err := tx.SavePoint(name); err != ErrArgs {
+			t.Errorf("SavePoint(%q) err = %v, want ErrArgs", name, err)
+		}
+		if err := tx.RollbackTo(name); err != ErrArgs {
+			t.Errorf("RollbackTo(%q) err = %v, want ErrArgs", name, err)
+		}
+		if err := tx.Release(name); err != ErrArgs {
+			t.Errorf("Release(%q) err = %v, want ErrArgs", name, err)
+		}
+	}
+	if err := tx.SavePoint("b"); err != ErrSavePointDuplicate {
+		t.Errorf("duplicate err = %v, want ErrSavePointDuplicate", err)
+	}
+	if err := tx.RollbackTo("b"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tx.savePoints, []string{"a", "b"}) {
+		t.Errorf("after RollbackTo savePoints = %v, want [a b]", tx.savePoints)
+	}
+	if err := tx.RollbackTo("c"); err != ErrSavePointNotFound {
+		t.Errorf("RollbackTo dropped savepoint err = %v, want ErrSavePointNotFound", err)
+	}
+	if err := tx.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.savePoints) != 0 {
+		t.Errorf("after Release savePoints = %v, want empty", tx.savePoints)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := tx.Release(name); err != ErrSavePointReleased {
+			t.Errorf("Release(%s) err = %v, want ErrSavePointReleased", name, err)
+		}
+	}
+	if err := tx.Release("missing"); err != ErrSavePointNotFound {
+		t.Errorf("unknown savepoint err = %v, want ErrSavePointNotFound", err)
+	}
+	if err := tx.SavePoint("a"); err != nil || !reflect.DeepEqual(tx.savePoints, []string{"a"}) {
+		t.Errorf("released name should be reusable, err = %v, savePoints = %v", err, tx.savePoints)
+	}
+	tx.Rollback()
+	if err := tx.SavePoint("d"); err != ErrTxDone {
+		t.Errorf("SavePoint after Rollback err = %v, want ErrTxDone", err)
+	}
+}
+
+func TestNestedTransaction(t *testing.T) {
+	db, state := newStubDataBase(t)
+	errBoom := errors.New("boom")
+	err := db.Transaction(func(tx *Tx) error {
+		if err := tx.Transaction(func(*Tx) error { return nil }); err != nil {
+			return err
+		}
+		if err := tx.DataBase.Transaction(func(*Tx) error { return errBoom }); err != errBoom {
+			t.Errorf("nested err = %v, want errBoom", err)
+		}
+		if len(tx.savePoints) != 0 {
+			t.Errorf("savePoints = %v, want empty", tx.savePoints)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BEGIN",
+		"SAVEPOINT `crud_sp_1`", "RELEASE SAVEPOINT `crud_sp_1`",
+		"SAVEPOINT `crud_sp_2`", "ROLLBACK TO SAVEPOINT `crud_sp_2`", "RELEASE SAVEPOINT `crud_sp_2`",
+		"COMMIT",
+	}
+	if got := state.statements(); !reflect.DeepEqual(got, want) {
+		t.Errorf("statements\n got: %q\nwant: %q", got, want)
+	}
+}
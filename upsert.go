@@ -0,0 +1,115 @@
+package crud
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//upsertAlias MySQL8.0.19之后 INSERT ... AS alias 中的别名
+const upsertAlias = "crud_new"
+
+//Upsert 插入，唯一键冲突的时候更新，使用一条 INSERT ... ON DUPLICATE KEY UPDATE 执行，不会有CreateOrUpdate的并发问题。
+/*
+	updateColumns为冲突时更新的字段，不传的时候更新m中除了id和created_at之外的所有字段。
+	表中有created_at的时候只在插入时设置，有updated_at的时候插入和更新都会设置。
+	默认使用 col = VALUES(col)，Config.UpsertAlias为true时使用MySQL8.0.19之后的 AS crud_new ... col = crud_new.col。
+
+	返回的id在更新时是被更新那一行的id，inserted根据RowsAffected判断：1为插入，2为更新，0为数据没有变化。
+	DSN中设置了clientFoundRows=true的时候数据没有变化的更新也返回1，和插入分不出来，这时inserted不可靠。
+	m和updateColumns不会被修改。
+*/
+func (t *Table) Upsert(m map[string]interface{}, updateColumns ...string) (id int64, inserted bool, err error) {
+	if len(m) == 0 {
+		return 0, false, ErrArgs
+	}
+	//复制一份，不修改调用方的map和切片
+	row := make(map[string]interface{}, len(m)+2)
+	for k, v := range m {
+		row[k] = v
+	}
+	m = row
+	updateColumns = append([]string(nil), updateColumns...)
+	now := time.Now().Format(TimeFormat)
+	columns := t.tableColumns[t.tableName]
+	if columns.HaveColumn(CreatedAt) {
+		m[CreatedAt] = now
+	}
+	if columns.HaveColumn(UpdatedAt) {
+		m[UpdatedAt] = now
+		if len(updateColumns) > 0 {
+			updateColumns = append(updateColumns, UpdatedAt)
+		}
+	}
+	query, args := t.upsertQuery(m, updateColumns)
+	ret := t.Exec(query, args...)
+	affected, err := ret.RowsAffected()
+	if err != nil {
+		return 0, false, convertError(err, t.tableName)
+	}
+	if id, err = ret.ID(); err != nil {
+		return 0, false, err
+	}
+	return id, affected == 1, nil
+}
+
+func (t *Table) upsertQuery(m map[string]interface{}, updateColumns []string) (string, []interface{}) {
+	cols := bulkColumns([]map[string]interface{}{m})
+	args := make([]interface{}, 0, len(cols))
+	for _, col := range cols {
+		args = append(args, m[strings.Trim(col, "`")])
+	}
+	if len(updateColumns) == 0 {
+		for k := range m {
+			if k != "id" && k != CreatedAt {
+				updateColumns = append(updateColumns, k)
+			}
+		}
+	}
+	updates := quoteColumns(updateColumns)
+	columns := t.tableColumns[t.tableName]
+	if columns == nil || columns.HaveColumn("id") {
+		//冲突的时候让LastInsertId返回被更新那一行的id
+		updates = append([]string{"`id` = LAST_INSERT_ID(`id`)"}, updates...)
+	}
+	query := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)", t.tableName, strings.Join(cols, ","), argslice(len(cols)))
+	if t.upsertAlias {
+		query += " AS `" + upsertAlias + "`"
+	}
+	for i, col := range updates {
+		if strings.Contains(col, "=") {
+			continue
+		}
+		if t.upsertAlias {
+			updates[i] = col + " = `" + upsertAlias + "`." + col
+		} else {
+			updates[i] = col + " = VALUES(" + col + ")"
+		}
+	}
+	return query + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", "), args
+}
+
+//Save 根据唯一键插入或者更新结构体，见Table.Upsert，ID为0的时候由数据库生成，保存后回填ID。
+//返回是否为插入，会调用BeforeSave、AfterSave钩子，Omit的字段不插入也不更新。
+func (db *DataBase) Save(obj interface{}) (bool, error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr {
+		return false, ErrMustNeedAddr
+	}
+	if err := callHook(v, BeforeSave, db); err != nil {
+		return false, err
+	}
+	m := structToMapFunc(v, db.updateFilter(nil))
+	if getStructID(v) == 0 {
+		delete(m, "id")
+	}
+	id, inserted, err := db.Table(getStructDBName(v)).Upsert(m)
+	if err != nil {
+		return false, err
+	}
+	if rID := v.Elem().FieldByName("ID"); rID.IsValid() && id > 0 {
+		rID.SetInt(id)
+	}
+	return inserted, callHook(v, AfterSave, db)
+}
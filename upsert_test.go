@@ -0,0 +1,104 @@
+package crud
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpsertQuery(t *testing.T) {
+	table := newTestDataBase().Table("task")
+	m := map[string]interface{}{"id": 0, "name": "a", "state": 1, "created_at": "2020-01-01 00:00:00"}
+	query, args := table.upsertQuery(m, nil)
+	want := "INSERT INTO `task` (`created_at`,`id`,`name`,`state`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `id` = LAST_INSERT_ID(`id`), `name` = VALUES(`name`), `state` = VALUES(`state`)"
+	if query != want {
+		t.Errorf("query\n got: %s\nwant: %s", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"2020-01-01 00:00:00", 0, "a", 1}) {
+		t.Errorf("args = %v", args)
+	}
+
+	table.upsertAlias = true
+	query, _ = table.upsertQuery(m, []string{"state"})
+	want = "INSERT INTO `task` (`created_at`,`id`,`name`,`state`) VALUES (?,?,?,?) AS `crud_new` ON DUPLICATE KEY UPDATE `id` = LAST_INSERT_ID(`id`), `state` = `crud_new`.`state`"
+	if query != want {
+		t.Errorf("query\n got: %s\nwant: %s", query, want)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	db, state := newStubDataBase(t)
+	db.tableColumns["task"]["updated_at"] = Column{Name: "updated_at"}
+	table := db.Table("task")
+	m := map[string]interface{}{"name": "a", "state": 1}
+	cols := make([]string, 1, 4)
+	cols[0] = "state"
+
+	state.id, state.affect = 7, 1
+	id, inserted, err := table.Upsert(m, cols...)
+	if err != nil || id != 7 || !inserted {
+		t.Fatalf("Upsert = %d, %v, %v", id, inserted, err)
+	}
+	statements := state.statements()
+	if got := statements[len(statements)-1]; !strings.HasSuffix(got, "ON DUPLICATE KEY UPDATE `id` = LAST_INSERT_ID(`id`), `state` = VALUES(`state`), `updated_at` = VALUES(`updated_at`)") {
+		t.Errorf("query = %s", got)
+	}
+	if len(m) != 2 || cols[:2][1] != "" {
+		t.Errorf("Upsert modified the arguments: %v %v", m, cols[:2])
+	}
+
+	for affect, want := range map[int64]bool{2: false, 0: false} {
+		state.affect = affect
+		if _, inserted, err := table.Upsert(m); err != nil || inserted != want {
+			t.Errorf("affected %d: inserted = %v, err = %v", affect, inserted, err)
+		}
+	}
+	if _, _, err := table.Upsert(map[string]interface{}{}); err != ErrArgs {
+		t.Errorf("err = %v, want ErrArgs", err)
+	}
+}
+
+type saveTask struct {
+	ID     int64
+	Name   string
+	before int `crud:"-"`
+	after  int `crud:"-"`
+}
+
+func (saveTask) DBName() string { return "task" }
+
+func (s *saveTask) BeforeSave() error {
+	s.before++
+	return nil
+}
+
+func (s *saveTask) AfterSave() error {
+	s.after++
+	return nil
+}
+
+func TestSave(t *testing.T) {
+	db, state := newStubDataBase(t)
+	state.id, state.affect = 42, 1
+	task := saveTask{Name: "a"}
+	inserted, err := db.Save(&task)
+	if err != nil || !inserted {
+		t.Fatalf("Save = %v, %v", inserted, err)
+	}
+	if task.ID != 42 || task.before != 1 || task.after != 1 {
+		t.Errorf("task = %+v", task)
+	}
+	statements := state.statements()
+	if got := statements[len(statements)-1]; !strings.HasPrefix(got, "INSERT INTO `task` (`created_at`,`name`) VALUES (?,?)") {
+		t.Errorf("query = %s", got)
+	}
+
+	state.id, state.affect = 5, 2
+	task = saveTask{ID: 5, Name: "b"}
+	if inserted, err := db.Save(&task); err != nil || inserted || task.ID != 5 {
+		t.Errorf("Save = %v, %v, task = %+v", inserted, err, task)
+	}
+	if _, err := db.Save(task); err != ErrMustNeedAddr {
+		t.Errorf("err = %v, want ErrMustNeedAddr", err)
+	}
+}
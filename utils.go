@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"unicode"
 )
 
 var (
@@ -106,6 +107,11 @@ func argslice(l int) string {
 
 //structToMap 将结构体转换成map[string]interface{}
 func structToMap(v reflect.Value) map[string]interface{} {
+	return structToMapFunc(v, nil)
+}
+
+//structToMapFunc 和structToMap一样，keep不为nil的时候只保留keep返回true的字段。
+func structToMapFunc(v reflect.Value, keep func(field reflect.StructField, dbName string, value reflect.Value) bool) map[string]interface{} {
 	v = reflect.Indirect(v)
 	t := v.Type()
 	m := map[string]interface{}{}
@@ -113,10 +119,12 @@ func structToMap(v reflect.Value) map[string]interface{} {
 	for i, num := 0, v.NumField(); i < num; i++ {
 		tag := t.Field(i).Tag
 		if tag.Get("crud") != "ignore" && tag.Get("crud") != "-" {
-			if tag.Get("dbname") != "" {
-				m[tag.Get("dbname")] = v.Field(i).Interface()
-			} else {
-				m[ToDBName(t.Field(i).Name)] = v.Field(i).Interface()
+			dbName := tag.Get("dbname")
+			if dbName == "" {
+				dbName = ToDBName(t.Field(i).Name)
+			}
+			if keep == nil || keep(t.Field(i), dbName, v.Field(i)) {
+				m[dbName] = v.Field(i).Interface()
 			}
 		}
 	}
@@ -124,6 +132,55 @@ func structToMap(v reflect.Value) map[string]interface{} {
 	return m
 }
 
+//isIdentifier 是否是 field 或者 table.field 这样可以直接加`的字段名
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return strings.Count(s, ".") <= 1 && s[0] != '.' && s[len(s)-1] != '.'
+}
+
+//splitTopLevel 按照不在括号和引号中的逗号分割
+func splitTopLevel(s string) []string {
+	var (
+		parts []string
+		depth int
+		quote rune
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+//likeReplacer 转义LIKE中的\、%和_
+var likeReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+//escapeLike 转义后可以放到LIKE中作为普通字符匹配
+func escapeLike(s string) string {
+	return likeReplacer.Replace(s)
+}
+
 func placeholder(n int) string {
 	holder := []string{}
 	for i := 0; i < n; i++ {